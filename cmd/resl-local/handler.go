@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+)
+
+// lambdaHandler adapts an API Gateway lambda handler to net/http for requests
+// with the given method, shaping each request the way API Gateway delivers it
+// (lowercased headers, base64 body)
+func lambdaHandler(method string, handler listener.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "unable to read body", http.StatusBadRequest)
+			return
+		}
+
+		headers := make(map[string]string, len(r.Header))
+		for name := range r.Header {
+			headers[strings.ToLower(name)] = r.Header.Get(name)
+		}
+
+		query := make(map[string]string)
+		for name := range r.URL.Query() {
+			query[name] = r.URL.Query().Get(name)
+		}
+
+		res, err := handler(r.Context(), events.APIGatewayProxyRequest{
+			Path:                  r.URL.Path,
+			HTTPMethod:            r.Method,
+			Headers:               headers,
+			QueryStringParameters: query,
+			Body:                  base64.StdEncoding.EncodeToString(body),
+			IsBase64Encoded:       true,
+		})
+		if err != nil {
+			// API Gateway answers a failed invocation with a bad gateway
+			log.Printf("Handler error: %s\n", err.Error())
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		for name, value := range res.Headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(res.StatusCode)
+		w.Write([]byte(res.Body))
+	}
+}
@@ -0,0 +1,83 @@
+// Command resl-local runs the listener, responder and executor in a single
+// process so changes can be tried against slack, through a tunnel, or against
+// a fake slack without deploying the lambdas
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/stripedpajamas/resl/executor"
+	"github.com/stripedpajamas/resl/invoker"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+	"github.com/stripedpajamas/resl/lambdas/slack_responder/responder"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/store"
+)
+
+var (
+	addr          = flag.String("addr", ":3000", "address to serve /run on")
+	languagesFile = flag.String("languages", "languages.json", "languages config, relative to the working directory")
+	skipVerify    = flag.Bool("skip-verify", false, "accept requests without a valid slack signature")
+	storeFile     = flag.String("store", "resl-local.json", "file to keep runs and installations in, or empty to keep them in memory")
+)
+
+func main() {
+	flag.Parse()
+
+	languages, err := models.ImportLanguageConfig(*languagesFile)
+	if err != nil {
+		log.Fatalf("Failed to load languages: %s\n", err.Error())
+	}
+
+	db, err := store.OpenFile(*storeFile)
+	if err != nil {
+		log.Fatalf("Failed to open the store: %s\n", err.Error())
+	}
+
+	slackClient := slack.NewClientFromEnv()
+
+	runner := &executor.Executor{}
+	r := responder.Responder{
+		Slack:    slackClient,
+		Store:    db,
+		Executor: &invoker.Local{Handler: invoker.RunnerHandler(runner)},
+	}
+
+	l := listener.Listener{
+		Languages: languages,
+		Slack:     slackClient,
+		Store:     db,
+		Responder: &invoker.Local{Handler: invoker.ResponderHandler(&r)},
+	}
+
+	oauth, err := listener.NewOAuthFromEnv(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// without the install flow every workspace uses SLACK_TOKEN
+	if oauth != nil {
+		l.Installations = db
+		r.Installations = db
+	}
+
+	handler := listener.HandlerFunc(l.HandleRequest)
+	if !*skipVerify {
+		handler = listener.AuthorizeRequest(handler)
+	} else {
+		log.Printf("Slack signature verification is disabled\n")
+	}
+
+	http.Handle("/run", lambdaHandler(http.MethodPost, handler))
+	http.Handle("/events", lambdaHandler(http.MethodPost, handler))
+
+	if oauth != nil {
+		http.Handle("/oauth/install", lambdaHandler(http.MethodGet, oauth.HandleRequest))
+		http.Handle("/oauth/callback", lambdaHandler(http.MethodGet, oauth.HandleRequest))
+	}
+
+	log.Printf("Listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
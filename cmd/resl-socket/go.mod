@@ -0,0 +1,24 @@
+module github.com/stripedpajamas/resl/cmd/resl-socket
+
+go 1.15
+
+require (
+	github.com/aws/aws-lambda-go v1.20.0
+	github.com/stripedpajamas/resl/executor v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/invoker v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/lambdas/slack_listener v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/lambdas/slack_responder v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/slack v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/store v0.0.0-00010101000000-000000000000
+)
+
+replace (
+	github.com/stripedpajamas/resl/executor => ../../executor
+	github.com/stripedpajamas/resl/invoker => ../../invoker
+	github.com/stripedpajamas/resl/lambdas/slack_listener => ../../lambdas/slack_listener
+	github.com/stripedpajamas/resl/lambdas/slack_responder => ../../lambdas/slack_responder
+	github.com/stripedpajamas/resl/models => ../../models
+	github.com/stripedpajamas/resl/slack => ../../slack
+	github.com/stripedpajamas/resl/store => ../../store
+)
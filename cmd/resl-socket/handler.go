@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+	"github.com/stripedpajamas/resl/slack/socketmode"
+)
+
+// envelopeHandler feeds socket mode envelopes to an API Gateway lambda
+// handler, shaping each payload like the HTTP request slack would otherwise
+// send, and acknowledges with the handler's json response
+func envelopeHandler(handler listener.HandlerFunc) socketmode.Handler {
+	return func(ctx context.Context, envelope socketmode.Envelope) (json.RawMessage, error) {
+		request, err := proxyRequest(envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := handler(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode >= 300 {
+			return nil, fmt.Errorf("handler answered with status %d", res.StatusCode)
+		}
+
+		if res.Body == "" || res.Headers["Content-Type"] != "application/json" {
+			return nil, nil
+		}
+		return json.RawMessage(res.Body), nil
+	}
+}
+
+// proxyRequest builds the request slack would post over HTTP for an envelope:
+// a form for slash commands and interactions, json for events
+func proxyRequest(envelope socketmode.Envelope) (events.APIGatewayProxyRequest, error) {
+	contentType := "application/x-www-form-urlencoded"
+
+	var body string
+	switch envelope.Type {
+	case socketmode.SlashCommandsType:
+		var fields map[string]interface{}
+		if err := json.Unmarshal(envelope.Payload, &fields); err != nil {
+			return events.APIGatewayProxyRequest{}, err
+		}
+
+		form := url.Values{}
+		for name, value := range fields {
+			form.Set(name, fmt.Sprint(value))
+		}
+		body = form.Encode()
+	case socketmode.InteractiveType:
+		body = url.Values{"payload": {string(envelope.Payload)}}.Encode()
+	case socketmode.EventsAPIType:
+		contentType = "application/json"
+		body = string(envelope.Payload)
+	default:
+		return events.APIGatewayProxyRequest{}, fmt.Errorf("unsupported envelope type %q", envelope.Type)
+	}
+
+	headers := map[string]string{
+		"content-type": contentType,
+	}
+	if envelope.RetryAttempt > 0 {
+		headers["x-slack-retry-num"] = strconv.Itoa(envelope.RetryAttempt)
+	}
+
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Headers:    headers,
+		Body:       body,
+	}, nil
+}
@@ -0,0 +1,98 @@
+// Command resl-socket runs resl over a socket mode connection so it needs no
+// public endpoint. Slash commands, interactions and events go through the
+// same listener and responder as the lambdas, in one long-running process
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/stripedpajamas/resl/executor"
+	"github.com/stripedpajamas/resl/invoker"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+	"github.com/stripedpajamas/resl/lambdas/slack_responder/responder"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/slack/socketmode"
+	"github.com/stripedpajamas/resl/store"
+)
+
+var (
+	languagesFile = flag.String("languages", "languages.json", "languages config, relative to the working directory")
+	storeFile     = flag.String("store", "resl-socket.json", "file to keep runs in, or empty to keep them in memory")
+)
+
+func main() {
+	flag.Parse()
+
+	appToken := os.Getenv("SLACK_APP_TOKEN")
+	if appToken == "" {
+		log.Fatalf("SLACK_APP_TOKEN must be set to an app-level token\n")
+	}
+
+	languages, err := models.ImportLanguageConfig(*languagesFile)
+	if err != nil {
+		log.Fatalf("Failed to load languages: %s\n", err.Error())
+	}
+
+	// run code in this process unless pointed at the code exec lambda
+	backend := os.Getenv("CODE_EXEC_INVOKER")
+	if backend == "" && os.Getenv("CODE_EXEC_LAMBDA_ARN") == "" {
+		backend = invoker.LocalBackend
+	}
+
+	runner, err := invoker.New(invoker.Config{
+		Backend:      backend,
+		FunctionName: os.Getenv("CODE_EXEC_LAMBDA_ARN"),
+		Handler:      invoker.RunnerHandler(&executor.Executor{}),
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up the code runner: %s\n", err.Error())
+	}
+
+	db, err := store.OpenFile(*storeFile)
+	if err != nil {
+		log.Fatalf("Failed to open the store: %s\n", err.Error())
+	}
+
+	slackClient := slack.NewClientFromEnv()
+
+	// there is no install flow here, so every workspace uses SLACK_TOKEN
+	r := responder.Responder{
+		Slack:    slackClient,
+		Store:    db,
+		Executor: runner,
+	}
+	if threshold, err := strconv.Atoi(os.Getenv("RESL_SNIPPET_THRESHOLD")); err == nil {
+		r.SnippetThreshold = threshold
+	}
+
+	l := listener.Listener{
+		Languages: languages,
+		Slack:     slackClient,
+		Store:     db,
+		Responder: &invoker.Local{Handler: invoker.ResponderHandler(&r)},
+	}
+
+	client := socketmode.New(appToken, envelopeHandler(l.HandleRequest))
+	client.API.BaseURL = os.Getenv("SLACK_API_URL")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Printf("Shutting down\n")
+		cancel()
+	}()
+
+	if err = client.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Socket mode stopped: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// DefaultCompileTimeout is the compile budget used when neither the language
+// nor the executor specifies one
+const DefaultCompileTimeout = 10 * time.Second
+
+// DefaultRunTimeout is the run budget used when neither the language nor the
+// executor specifies one
+const DefaultRunTimeout = 8 * time.Second
+
+// DefaultKillAfter is how long a timed out process has to exit after SIGTERM
+// before it is sent SIGKILL
+const DefaultKillAfter = 2 * time.Second
+
+// DefaultReserve is how much time before the context's deadline is kept back
+// for returning the result. Compile and run budgets are cut down to fit in
+// what is left, so a lambda's timeout can't kill the executor before a
+// timed out program is reported
+const DefaultReserve = 2 * time.Second
+
+// DefaultMaxOutput is how many bytes of each of stdout and stderr are kept;
+// the rest is read and thrown away. A compiled language's output holds up to
+// four times this much
+const DefaultMaxOutput = 1 << 20
+
+// Executor writes submitted code to a scratch directory and runs it with the
+// language's configured commands. The zero value is ready to use
+type Executor struct {
+	// TempDir is where per-run work directories are created; defaults to os.TempDir()
+	TempDir string
+	// CompileTimeout overrides DefaultCompileTimeout for languages without a compileTimeout
+	CompileTimeout time.Duration
+	// RunTimeout overrides DefaultRunTimeout for languages without a runTimeout
+	RunTimeout time.Duration
+	// KillAfter overrides DefaultKillAfter
+	KillAfter time.Duration
+	// MaxOutput overrides DefaultMaxOutput
+	MaxOutput int
+	// Reserve overrides DefaultReserve
+	Reserve time.Duration
+}
+
+// Run writes the request's code to disk, compiles it if the language has a
+// compile command, and runs it. A non-nil error means the code could not be
+// run at all; compile failures and non-zero exits are reported in the output
+func (e *Executor) Run(ctx context.Context, request models.CodeProcessRequest) (models.CodeOutput, error) {
+	props := request.Props
+	if strings.TrimSpace(props.RunCommand) == "" {
+		return models.CodeOutput{}, errors.New("no run command configured for language")
+	}
+
+	workDir, err := ioutil.TempDir(e.TempDir, "resl-")
+	if err != nil {
+		return models.CodeOutput{}, err
+	}
+	defer func() {
+		log.Printf("Deleting work dir: %s\n", workDir)
+		os.RemoveAll(workDir)
+	}()
+
+	// commands run inside the work dir, so diagnostics only show the bare file name
+	name := fileName(props)
+	filePath := filepath.Join(workDir, name)
+	log.Printf("Writing file: %s\n", filePath)
+	if err = ioutil.WriteFile(filePath, []byte(request.Code), 0644); err != nil {
+		return models.CodeOutput{}, err
+	}
+
+	var output models.CodeOutput
+	runArgs := []string{name}
+
+	if strings.TrimSpace(props.CompileCommand) != "" {
+		log.Printf("Compiling code: %s\n", props.CompileCommand)
+
+		bin, args := splitCommand(props.CompileCommand)
+		killAfter := budget(0, e.KillAfter, DefaultKillAfter)
+		compiled, err := execute(ctx, command{
+			dir:       workDir,
+			bin:       bin,
+			args:      append(args, name),
+			timeout:   e.fit(ctx, budget(props.CompileTimeout, e.CompileTimeout, DefaultCompileTimeout), killAfter),
+			killAfter: killAfter,
+			maxOutput: e.maxOutput(),
+		})
+		if err != nil {
+			return models.CodeOutput{}, err
+		}
+
+		output.Compile = &compiled
+		if output.CompileFailed() {
+			return output, nil
+		}
+
+		// compiled programs are run without the source file as an argument
+		runArgs = nil
+	}
+
+	log.Printf("Running code: %s\n", props.RunCommand)
+
+	bin, args := splitCommand(props.RunCommand)
+	killAfter := budget(0, e.KillAfter, DefaultKillAfter)
+	run, err := execute(ctx, command{
+		dir:       workDir,
+		bin:       bin,
+		args:      append(args, runArgs...),
+		stdin:     request.Stdin,
+		timeout:   e.fit(ctx, budget(props.RunTimeout, e.RunTimeout, DefaultRunTimeout), killAfter),
+		killAfter: killAfter,
+		maxOutput: e.maxOutput(),
+	})
+	if err != nil {
+		return models.CodeOutput{}, err
+	}
+
+	output.Run = &run
+
+	return output, nil
+}
+
+// fileName is the language's required file name (e.g. Main.java) or a
+// generic name with the language's extension
+func fileName(props models.LanguageProperties) string {
+	if props.FileName != "" {
+		return props.FileName
+	}
+	return "code." + props.Extension
+}
+
+// splits a command string like "gcc -O2 -o main" into the binary and its args
+func splitCommand(cmd string) (string, []string) {
+	fields := strings.Fields(cmd)
+	return fields[0], fields[1:]
+}
+
+// picks the language's budget (in seconds), then the executor's, then the default
+func budget(seconds int, configured, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if configured > 0 {
+		return configured
+	}
+	return fallback
+}
+
+// fit cuts a phase's timeout down so that the phase, the kill-after window and
+// the reserve all end before ctx's deadline
+func (e *Executor) fit(ctx context.Context, timeout, killAfter time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+
+	left := time.Until(deadline) - killAfter - budget(0, e.Reserve, DefaultReserve)
+	if left < timeout {
+		log.Printf("Only %s left before the deadline, cutting the %s budget\n", left, timeout)
+		timeout = left
+	}
+	if timeout < 0 {
+		timeout = 0
+	}
+	return timeout
+}
+
+func (e *Executor) maxOutput() int {
+	if e.MaxOutput > 0 {
+		return e.MaxOutput
+	}
+	return DefaultMaxOutput
+}
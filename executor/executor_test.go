@@ -0,0 +1,289 @@
+//go:build !windows
+// +build !windows
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// the language used by the tests: the code is a shell script, and "compiling"
+// it runs it too so a script can fail compilation
+var shell = models.LanguageProperties{
+	ShortName:  "sh",
+	Extension:  "sh",
+	RunCommand: "sh",
+}
+
+var shellCompiled = models.LanguageProperties{
+	ShortName:      "sh",
+	Extension:      "sh",
+	CompileCommand: "sh",
+	RunCommand:     "true",
+}
+
+// running reports whether the process is alive, counting zombies as dead
+func running(pid int) bool {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return syscall.Kill(pid, 0) == nil
+	}
+	// the state follows the command name, which is in parentheses
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		props    models.LanguageProperties
+		code     string
+		stdin    string
+		executor Executor
+		// deadline, when set, is how long the context has
+		deadline time.Duration
+		check    func(t *testing.T, output models.CodeOutput)
+	}{
+		{
+			name:  "compile failure",
+			props: shellCompiled,
+			code:  "echo 'code.sh:1: error: expected ;' >&2; exit 1",
+			check: func(t *testing.T, output models.CodeOutput) {
+				if !output.CompileFailed() {
+					t.Fatalf("expected compilation to fail, got %+v", output.Compile)
+				}
+				if output.Run != nil {
+					t.Errorf("expected no run after failed compilation, got %+v", output.Run)
+				}
+				if output.Compile.ExitCode != 1 {
+					t.Errorf("expected exit 1, got %d", output.Compile.ExitCode)
+				}
+				if output.Compile.Stderr != "code.sh:1: error: expected ;\n" {
+					t.Errorf("unexpected compiler output %q", output.Compile.Stderr)
+				}
+			},
+		},
+		{
+			name:  "compile success",
+			props: shellCompiled,
+			code:  "exit 0",
+			check: func(t *testing.T, output models.CodeOutput) {
+				if output.CompileFailed() || output.Run == nil || !output.Run.Succeeded() {
+					t.Fatalf("expected compile and run to succeed, got %+v %+v", output.Compile, output.Run)
+				}
+			},
+		},
+		{
+			name:  "non-zero exit",
+			props: shell,
+			code:  "echo out; echo err >&2; exit 3",
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if run.ExitCode != 3 || run.Signal != "" || run.TimedOut {
+					t.Errorf("expected a plain exit 3, got %+v", run)
+				}
+				if run.Stdout != "out\n" || run.Stderr != "err\n" {
+					t.Errorf("expected separate stdout and stderr, got %q and %q", run.Stdout, run.Stderr)
+				}
+			},
+		},
+		{
+			name:  "killed by signal",
+			props: shell,
+			code:  "kill -SEGV $$",
+			check: func(t *testing.T, output models.CodeOutput) {
+				if output.Run.Signal != "SIGSEGV" || output.Run.TimedOut {
+					t.Errorf("expected death by SIGSEGV, got %+v", output.Run)
+				}
+			},
+		},
+		{
+			name:     "timeout sends SIGTERM",
+			props:    shell,
+			code:     "sleep 10",
+			executor: Executor{RunTimeout: 100 * time.Millisecond, KillAfter: 5 * time.Second},
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.TimedOut || run.Signal != "SIGTERM" {
+					t.Errorf("expected a timeout ended by SIGTERM, got %+v", run)
+				}
+				if run.DurationMs >= 5000 {
+					t.Errorf("expected SIGTERM to be enough, took %dms", run.DurationMs)
+				}
+			},
+		},
+		{
+			name:     "timeout escalates to SIGKILL",
+			props:    shell,
+			code:     "trap '' TERM; while :; do sleep 0.05; done",
+			executor: Executor{RunTimeout: 100 * time.Millisecond, KillAfter: 300 * time.Millisecond},
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.TimedOut || run.Signal != "SIGKILL" {
+					t.Errorf("expected a timeout ended by SIGKILL, got %+v", run)
+				}
+				if run.DurationMs < 400 {
+					t.Errorf("expected SIGKILL only after the kill-after window, took %dms", run.DurationMs)
+				}
+			},
+		},
+		{
+			name:  "background processes are cleaned up",
+			props: shell,
+			code:  "sleep 30 & echo $!",
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.Succeeded() || run.DurationMs >= 5000 {
+					t.Fatalf("expected the script to finish without waiting for its child, got %+v", run)
+				}
+				pid, err := strconv.Atoi(strings.TrimSpace(run.Stdout))
+				if err != nil {
+					t.Fatalf("expected the child's pid, got %q", run.Stdout)
+				}
+				for i := 0; i < 50 && running(pid); i++ {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if running(pid) {
+					syscall.Kill(pid, syscall.SIGKILL)
+					t.Errorf("expected child %d to be killed with the process group", pid)
+				}
+			},
+		},
+		{
+			name:     "budgets fit before the deadline",
+			props:    shell,
+			code:     "trap '' TERM; while :; do sleep 0.05; done",
+			executor: Executor{RunTimeout: 10 * time.Second, KillAfter: 300 * time.Millisecond, Reserve: 300 * time.Millisecond},
+			deadline: 1500 * time.Millisecond,
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.TimedOut || run.Signal != "SIGKILL" {
+					t.Errorf("expected a timeout ended by SIGKILL, got %+v", run)
+				}
+				// the run gets 1500 - 300 - 300ms and then the kill-after
+				// window, leaving the reserve before the deadline
+				if run.DurationMs >= 1400 {
+					t.Errorf("expected the run to be killed before the reserve, took %dms", run.DurationMs)
+				}
+			},
+		},
+		{
+			name:     "timed out process group is killed",
+			props:    shell,
+			code:     "sleep 30 & echo $!; wait",
+			executor: Executor{RunTimeout: 100 * time.Millisecond, KillAfter: time.Second},
+			check: func(t *testing.T, output models.CodeOutput) {
+				pid, err := strconv.Atoi(strings.TrimSpace(output.Run.Stdout))
+				if err != nil {
+					t.Fatalf("expected the child's pid, got %q", output.Run.Stdout)
+				}
+				for i := 0; i < 50 && running(pid); i++ {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if running(pid) {
+					syscall.Kill(pid, syscall.SIGKILL)
+					t.Errorf("expected child %d to be killed with the process group", pid)
+				}
+			},
+		},
+		{
+			name:  "stdin is piped",
+			props: shell,
+			code:  "read a; read b; echo \"$b $a\"",
+			stdin: "world\nhello\n",
+			check: func(t *testing.T, output models.CodeOutput) {
+				if output.Run.Stdout != "hello world\n" {
+					t.Errorf("expected the input echoed back, got %q", output.Run.Stdout)
+				}
+			},
+		},
+		{
+			name:  "unread stdin",
+			props: shell,
+			code:  "echo done",
+			stdin: strings.Repeat("x", 1<<20),
+			check: func(t *testing.T, output models.CodeOutput) {
+				if !output.Run.Succeeded() || output.Run.Stdout != "done\n" {
+					t.Errorf("expected the script to ignore its input, got %+v", output.Run)
+				}
+			},
+		},
+		{
+			name:     "output is capped",
+			props:    shell,
+			code:     "yes | head -c 100000; yes error | head -c 50 >&2",
+			executor: Executor{MaxOutput: 1000},
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.Succeeded() || !run.Truncated {
+					t.Errorf("expected a truncated successful run, got exit %d truncated %v", run.ExitCode, run.Truncated)
+				}
+				if len(run.Stdout) != 1000 || len(run.Stderr) != 50 {
+					t.Errorf("expected 1000 bytes of stdout and all of stderr, got %d and %d", len(run.Stdout), len(run.Stderr))
+				}
+			},
+		},
+		{
+			name:     "endless output is capped",
+			props:    shell,
+			code:     "yes",
+			executor: Executor{RunTimeout: 200 * time.Millisecond, MaxOutput: 1000},
+			check: func(t *testing.T, output models.CodeOutput) {
+				run := output.Run
+				if !run.TimedOut || !run.Truncated || len(run.Stdout) != 1000 {
+					t.Errorf("expected a timed out run with 1000 bytes of output, got timed out %v truncated %v and %d bytes", run.TimedOut, run.Truncated, len(run.Stdout))
+				}
+			},
+		},
+		{
+			name:     "capped output keeps whole characters",
+			props:    shell,
+			code:     "printf 'aéé'",
+			executor: Executor{MaxOutput: 4},
+			check: func(t *testing.T, output models.CodeOutput) {
+				if output.Run.Stdout != "aé" {
+					t.Errorf("expected the cut character dropped, got %q", output.Run.Stdout)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if test.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.deadline)
+				defer cancel()
+			}
+
+			executor := test.executor
+			executor.TempDir = t.TempDir()
+
+			output, err := executor.Run(ctx, models.CodeProcessRequest{
+				Code:  test.code,
+				Props: test.props,
+				Stdin: test.stdin,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !output.CompileFailed() && output.Run == nil {
+				t.Fatalf("expected a run, got none")
+			}
+			test.check(t, output)
+		})
+	}
+}
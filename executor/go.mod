@@ -0,0 +1,7 @@
+module github.com/stripedpajamas/resl/executor
+
+go 1.15
+
+require github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+
+replace github.com/stripedpajamas/resl/models => ../models
@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+type command struct {
+	dir       string
+	bin       string
+	args      []string
+	stdin     string
+	timeout   time.Duration
+	killAfter time.Duration
+	maxOutput int
+}
+
+// cappedBuffer keeps the first max bytes written to it and quietly discards
+// the rest, so a program printing forever can't run the executor out of memory
+// while it still drains the pipe and doesn't block
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns what was kept, without a character cut in half at the cap
+func (b *cappedBuffer) String() string {
+	out := b.buf.Bytes()
+	if b.truncated {
+		for i := len(out) - 1; i >= 0 && i >= len(out)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(out[i]) {
+				if !utf8.FullRune(out[i:]) {
+					out = out[:i]
+				}
+				break
+			}
+		}
+	}
+	return string(out)
+}
+
+// pipes feeds stdin to a command and drains its stdout and stderr. exec would
+// do this itself, but then Wait also waits for anything the program left
+// running in the background to let go of the pipes
+type pipes struct {
+	// the ends the child uses, closed here once it has started
+	child []*os.File
+	// the ends read and written here
+	parent  []*os.File
+	copying sync.WaitGroup
+}
+
+func attachPipes(cmd *exec.Cmd, stdin string, stdout, stderr io.Writer) (*pipes, error) {
+	p := &pipes{}
+
+	// each pipe is the child's end followed by this process's
+	for i := 0; i < 3; i++ {
+		read, write, err := os.Pipe()
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		if i == 0 {
+			p.child, p.parent = append(p.child, read), append(p.parent, write)
+		} else {
+			p.child, p.parent = append(p.child, write), append(p.parent, read)
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = p.child[0], p.child[1], p.child[2]
+
+	p.copying.Add(3)
+	go func() {
+		defer p.copying.Done()
+		// a program that exits without reading its input closes the pipe early
+		io.Copy(p.parent[0], strings.NewReader(stdin))
+		p.parent[0].Close()
+	}()
+	go p.drain(p.parent[1], stdout)
+	go p.drain(p.parent[2], stderr)
+
+	return p, nil
+}
+
+func (p *pipes) drain(src *os.File, dst io.Writer) {
+	defer p.copying.Done()
+	io.Copy(dst, src)
+}
+
+// started closes this process's copies of the child's ends, so reading sees
+// the end of output once the child's process group is gone
+func (p *pipes) started() {
+	for _, f := range p.child {
+		f.Close()
+	}
+}
+
+func (p *pipes) close() {
+	p.started()
+	for _, f := range p.parent {
+		f.Close()
+	}
+}
+
+// wait waits for the output to be read, giving up after grace if something
+// outside the process group is still holding the pipes open
+func (p *pipes) wait(grace time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		p.copying.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+		log.Printf("Output pipes still open, closing them\n")
+		p.close()
+		<-done
+	}
+}
+
+// execute runs a command to completion, capturing stdout and stderr
+// separately. When the timeout elapses or ctx is done the process group gets
+// SIGTERM, then SIGKILL if it is still around after killAfter. Anything left
+// in the process group when the command exits is killed
+func execute(ctx context.Context, c command) (models.ExecutionResult, error) {
+	stdout := &cappedBuffer{max: c.maxOutput}
+	stderr := &cappedBuffer{max: c.maxOutput}
+
+	cmd := exec.Command(c.bin, c.args...)
+	cmd.Dir = c.dir
+	setProcessGroup(cmd)
+
+	streams, err := attachPipes(cmd, c.stdin, stdout, stderr)
+	if err != nil {
+		return models.ExecutionResult{}, err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		streams.close()
+		return models.ExecutionResult{}, err
+	}
+	streams.started()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	timedOut := false
+
+	select {
+	case err = <-done:
+	case <-timer.C:
+		timedOut = true
+	case <-ctx.Done():
+		timedOut = true
+	}
+
+	if timedOut {
+		log.Printf("Process %d timed out, sending SIGTERM\n", cmd.Process.Pid)
+		terminate(cmd)
+
+		select {
+		case err = <-done:
+		case <-time.After(c.killAfter):
+			log.Printf("Process %d still running, sending SIGKILL\n", cmd.Process.Pid)
+			kill(cmd)
+			err = <-done
+		}
+	}
+	duration := time.Since(start)
+
+	// clean up anything the program started in the background
+	kill(cmd)
+	streams.wait(c.killAfter)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return models.ExecutionResult{}, err
+	}
+
+	return models.ExecutionResult{
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		ExitCode:   cmd.ProcessState.ExitCode(),
+		Signal:     signalName(cmd.ProcessState),
+		DurationMs: duration.Milliseconds(),
+		TimedOut:   timedOut,
+		Truncated:  stdout.truncated || stderr.truncated,
+	}, nil
+}
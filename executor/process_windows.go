@@ -0,0 +1,21 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// windows has no SIGTERM, so both steps kill the process outright
+func terminate(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func signalName(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,64 @@
+package invoker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Backend names accepted by New
+const (
+	LambdaBackend = "lambda"
+	QueueBackend  = "queue"
+	LocalBackend  = "local"
+)
+
+// ErrSyncUnsupported is returned by backends that can only hand payloads off
+var ErrSyncUnsupported = errors.New("invoker does not support synchronous invocation")
+
+// Invoker hands a JSON payload to another stage of the pipeline, such as the
+// responder or the code runner
+type Invoker interface {
+	// Invoke processes the payload and waits for the response payload
+	Invoke(ctx context.Context, payload []byte) ([]byte, error)
+	// InvokeAsync hands the payload off without waiting for it to be processed
+	InvokeAsync(ctx context.Context, payload []byte) error
+}
+
+// HandlerFunc processes a payload in-process
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// Config selects and configures an Invoker backend
+type Config struct {
+	// Backend is one of LambdaBackend (the default), QueueBackend or LocalBackend
+	Backend string
+	// FunctionName is the lambda name or ARN for LambdaBackend
+	FunctionName string
+	// QueueURL is the SQS queue for QueueBackend
+	QueueURL string
+	// Handler processes payloads for LocalBackend
+	Handler HandlerFunc
+}
+
+// New returns the Invoker for the configured backend
+func New(config Config) (Invoker, error) {
+	switch config.Backend {
+	case "", LambdaBackend:
+		if config.FunctionName == "" {
+			return nil, errors.New("lambda invoker needs a function name")
+		}
+		return NewLambda(config.FunctionName), nil
+	case QueueBackend:
+		if config.QueueURL == "" {
+			return nil, errors.New("queue invoker needs a queue url")
+		}
+		return NewQueue(config.QueueURL), nil
+	case LocalBackend:
+		if config.Handler == nil {
+			return nil, errors.New("local invoker needs a handler")
+		}
+		return &Local{Handler: config.Handler}, nil
+	default:
+		return nil, fmt.Errorf("unknown invoker backend %q", config.Backend)
+	}
+}
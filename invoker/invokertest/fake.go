@@ -0,0 +1,56 @@
+// Package invokertest provides a fake invoker.Invoker for tests
+package invokertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stripedpajamas/resl/invoker"
+)
+
+// Call records one invocation of the fake
+type Call struct {
+	Payload []byte
+	Async   bool
+}
+
+// Fake records every payload it is given and answers synchronous invocations
+// with Response and Err
+type Fake struct {
+	Response []byte
+	Err      error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Invoke records the payload and returns the configured response
+func (f *Fake) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	f.record(Call{Payload: payload})
+	return f.Response, f.Err
+}
+
+// InvokeAsync records the payload and returns the configured error
+func (f *Fake) InvokeAsync(ctx context.Context, payload []byte) error {
+	f.record(Call{Payload: payload, Async: true})
+	return f.Err
+}
+
+// Calls returns the invocations made so far, oldest first
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([]Call, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+func (f *Fake) record(call Call) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+}
+
+var _ invoker.Invoker = (*Fake)(nil)
@@ -0,0 +1,70 @@
+package invoker
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	lambdaClient "github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// FunctionError is returned when the invoked lambda itself returned an error
+type FunctionError struct {
+	Type    string
+	Payload []byte
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("lambda function error (%s): %s", e.Type, string(e.Payload))
+}
+
+// Lambda invokes an AWS lambda function
+type Lambda struct {
+	FunctionName string
+	Client       *lambdaClient.Lambda
+}
+
+// NewLambda returns an invoker for the named function using the default AWS
+// session for the current region
+func NewLambda(functionName string) *Lambda {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	return &Lambda{
+		FunctionName: functionName,
+		Client:       lambdaClient.New(sess, &aws.Config{Region: aws.String(os.Getenv("AWS_REGION"))}),
+	}
+}
+
+// Invoke runs the function and returns its response payload
+func (l *Lambda) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	output, err := l.Client.InvokeWithContext(ctx, &lambdaClient.InvokeInput{
+		FunctionName: aws.String(l.FunctionName),
+		Payload:      payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.FunctionError != nil {
+		return nil, &FunctionError{
+			Type:    aws.StringValue(output.FunctionError),
+			Payload: output.Payload,
+		}
+	}
+
+	return output.Payload, nil
+}
+
+// InvokeAsync queues an event invocation of the function
+func (l *Lambda) InvokeAsync(ctx context.Context, payload []byte) error {
+	_, err := l.Client.InvokeWithContext(ctx, &lambdaClient.InvokeInput{
+		FunctionName:   aws.String(l.FunctionName),
+		Payload:        payload,
+		InvocationType: aws.String("Event"),
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package invoker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// Local processes payloads with a handler in the same process
+type Local struct {
+	Handler HandlerFunc
+}
+
+// Invoke calls the handler directly
+func (l *Local) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return l.Handler(ctx, payload)
+}
+
+// InvokeAsync calls the handler on its own goroutine. The caller's context is
+// not passed along since the handler usually outlives the caller's request
+func (l *Local) InvokeAsync(ctx context.Context, payload []byte) error {
+	go func() {
+		if _, err := l.Handler(context.Background(), payload); err != nil {
+			log.Printf("Error while handling async invocation: %s\n", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// CodeRunner runs the code in a request, like the executor
+type CodeRunner interface {
+	Run(ctx context.Context, request models.CodeProcessRequest) (models.CodeOutput, error)
+}
+
+// Responder handles a request from the listener, like the responder
+type Responder interface {
+	HandleRequest(ctx context.Context, request models.CodeProcessRequest) error
+}
+
+// RunnerHandler runs a serialized code process request with the runner, in
+// place of the code exec lambda
+func RunnerHandler(runner CodeRunner) HandlerFunc {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		var request models.CodeProcessRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		output, err := runner.Run(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(output)
+	}
+}
+
+// ResponderHandler passes a serialized code process request to the
+// responder, in place of the responder lambda
+func ResponderHandler(responder Responder) HandlerFunc {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		var request models.CodeProcessRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		return nil, responder.HandleRequest(ctx, request)
+	}
+}
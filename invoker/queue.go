@@ -0,0 +1,44 @@
+package invoker
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Queue sends payloads to an SQS queue for a consumer to process. It only
+// supports asynchronous invocation
+type Queue struct {
+	QueueURL string
+	Client   *sqs.SQS
+}
+
+// NewQueue returns an invoker for the queue using the default AWS session for
+// the current region
+func NewQueue(queueURL string) *Queue {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	return &Queue{
+		QueueURL: queueURL,
+		Client:   sqs.New(sess, &aws.Config{Region: aws.String(os.Getenv("AWS_REGION"))}),
+	}
+}
+
+// Invoke always fails with ErrSyncUnsupported
+func (q *Queue) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return nil, ErrSyncUnsupported
+}
+
+// InvokeAsync sends the payload as the body of a queue message
+func (q *Queue) InvokeAsync(ctx context.Context, payload []byte) error {
+	_, err := q.Client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.QueueURL),
+		MessageBody: aws.String(string(payload)),
+	})
+	return err
+}
@@ -1,6 +1,6 @@
 #!/bin/sh
 if [ -z "${AWS_LAMBDA_RUNTIME_API}" ]; then
-    exec /usr/bin/aws-lambda-rie /usr/local/bin/npx aws-lambda-ric $1
+    exec /usr/bin/aws-lambda-rie "$@"
 else
-    exec /usr/local/bin/npx aws-lambda-ric $1
+    exec "$@"
 fi
@@ -0,0 +1,14 @@
+module github.com/stripedpajamas/resl/lambdas/code_exec
+
+go 1.15
+
+require (
+	github.com/aws/aws-lambda-go v1.20.0
+	github.com/stripedpajamas/resl/executor v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+)
+
+replace (
+	github.com/stripedpajamas/resl/executor => ../../executor
+	github.com/stripedpajamas/resl/models => ../../models
+)
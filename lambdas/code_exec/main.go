@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/stripedpajamas/resl/executor"
+	"github.com/stripedpajamas/resl/models"
+)
+
+// maxOutput is how many bytes of each stream are kept. The compile and run
+// stdout and stderr all come back in one response, which json escaping can
+// grow several times over, and lambda responses are capped at 6 MB
+const maxOutput = 128 << 10
+
+var runner = executor.Executor{
+	TempDir:   os.TempDir(),
+	MaxOutput: maxOutput,
+}
+
+func handleRequest(ctx context.Context, request models.CodeProcessRequest) (models.CodeOutput, error) {
+	log.Printf("Running %s code\n", request.Props.ShortName)
+
+	output, err := runner.Run(ctx, request)
+	if err != nil {
+		log.Printf("Error while running code: %s\n", err.Error())
+		return models.CodeOutput{}, err
+	}
+
+	return output, nil
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
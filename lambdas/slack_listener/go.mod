@@ -1,4 +1,4 @@
-module github.com/stripedpajamas/resl/lambdas/slack_responder
+module github.com/stripedpajamas/resl/lambdas/slack_listener
 
 go 1.15
 
@@ -6,6 +6,15 @@ require (
 	github.com/aws/aws-lambda-go v1.20.0
 	github.com/aws/aws-sdk-go v1.36.12
 	github.com/gorilla/schema v1.2.0
-	github.com/stripedpajamas/resl/models v0.0.0-20201219014342-23666e0fed3f
-	github.com/stripedpajamas/resl/slack v0.0.0-20201219014342-23666e0fed3f
+	github.com/stripedpajamas/resl/invoker v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/slack v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/store v0.0.0-00010101000000-000000000000
+)
+
+replace (
+	github.com/stripedpajamas/resl/invoker => ../../invoker
+	github.com/stripedpajamas/resl/models => ../../models
+	github.com/stripedpajamas/resl/slack => ../../slack
+	github.com/stripedpajamas/resl/store => ../../store
 )
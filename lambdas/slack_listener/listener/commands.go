@@ -0,0 +1,127 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// Version is the release of resl, set at build time with
+// -ldflags "-X github.com/stripedpajamas/resl/lambdas/slack_listener/listener.Version=v1.2.3"
+var Version = "dev"
+
+// subcommands that aren't about snippets or history
+const (
+	helpCommand    = "help"
+	versionCommand = "version"
+)
+
+// commandHandler answers a subcommand, given the text after its name
+type commandHandler func(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error)
+
+// command is a word that, at the start of a slash command, does something
+// other than run code
+type command struct {
+	Name string
+	// Args describes the arguments in the help, e.g. "<name> [input]"
+	Args    string
+	Summary string
+	Handle  commandHandler
+}
+
+// router finds the command named by the first word of a slash command. Text
+// that doesn't start with a command is code for the language it starts with
+type router struct {
+	commands []command
+	byName   map[string]command
+}
+
+func newRouter(commands ...command) *router {
+	r := &router{byName: make(map[string]command)}
+	for _, c := range commands {
+		r.register(c)
+	}
+	return r
+}
+
+// register adds the command, which then shadows any language of the same
+// name. A command registered again replaces the earlier one in its place
+func (r *router) register(c command) {
+	key := strings.ToLower(c.Name)
+	if _, found := r.byName[key]; !found {
+		r.commands = append(r.commands, c)
+	} else {
+		for i := range r.commands {
+			if strings.ToLower(r.commands[i].Name) == key {
+				r.commands[i] = c
+			}
+		}
+	}
+	r.byName[key] = c
+}
+
+// lookup returns the command with the name, ignoring case
+func (r *router) lookup(name string) (command, bool) {
+	c, found := r.byName[strings.ToLower(name)]
+	return c, found
+}
+
+// help lists the commands in the order they were registered
+func (r *router) help(slashCommand string) string {
+	var b strings.Builder
+	for _, c := range r.commands {
+		usage := slashCommand + " " + c.Name
+		if c.Args != "" {
+			usage += " " + c.Args
+		}
+		b.WriteString(fmt.Sprintf("• `%s` %s\n", usage, c.Summary))
+	}
+	return b.String()
+}
+
+// returns the slash command the user typed, for usage messages
+func commandName(body slack.Request) string {
+	if body.AppCommand == "" {
+		return "/resl"
+	}
+	return body.AppCommand
+}
+
+// commands returns the subcommands the listener answers
+func (l *Listener) commands() *router {
+	return newRouter(
+		command{Name: helpCommand, Summary: "shows this help", Handle: l.handleHelp},
+		command{Name: langsCommand, Summary: "lists the languages you can run", Handle: l.handleLangs},
+		command{Name: historyCommand, Summary: "lists your recent runs", Handle: l.handleHistory},
+		command{Name: saveCommand, Args: "[" + sharedFlag + "] <name> <language> <code>", Summary: "saves code under a name, shared with the channel with " + sharedFlag, Handle: l.handleSave},
+		command{Name: runCommand, Args: "<name> [input]", Summary: "runs a saved snippet", Handle: l.handleRun},
+		command{Name: snippetsCommand, Summary: "lists your snippets and the channel's", Handle: l.handleSnippets},
+		command{Name: deleteCommand, Args: "[" + sharedFlag + "] <name>", Summary: "deletes a snippet", Handle: l.handleDelete},
+		command{Name: versionCommand, Summary: "shows which version of resl is running", Handle: l.handleVersion},
+	)
+}
+
+// explains how to run code and lists the subcommands
+func (l *Listener) handleHelp(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	name := commandName(body)
+
+	var b strings.Builder
+	b.WriteString("*Running code*\n")
+	b.WriteString(fmt.Sprintf("• `%s <language> <code>` runs the code and posts the result in the channel. The code can be wrapped in backticks\n", name))
+	b.WriteString("• The language can be an alias, e.g. `python`, or the tag of the code block, e.g. ```python\n")
+	b.WriteString(fmt.Sprintf("• `%s <language> <code> --stdin <input>`, or a second code block after the code, passes input to the program\n", name))
+	b.WriteString(fmt.Sprintf("• `%s` on its own, or with just a language, opens an editor\n", name))
+	b.WriteString("• The app's message shortcut, or mentioning the app, runs the code in a message\n")
+	b.WriteString(fmt.Sprintf("See `%s %s` for the languages you can use\n", name, langsCommand))
+	b.WriteString("*Commands*\n")
+	b.WriteString(l.commands().help(name))
+
+	return privateText(b.String())
+}
+
+func (l *Listener) handleVersion(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	return privateText("resl " + Version)
+}
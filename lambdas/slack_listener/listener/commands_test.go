@@ -0,0 +1,126 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/invoker/invokertest"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// handles a command by answering with its name and arguments
+func echo(name string) commandHandler {
+	return func(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: name + ":" + args}, nil
+	}
+}
+
+func TestRouterLookup(t *testing.T) {
+	r := newRouter(
+		command{Name: "help", Handle: echo("help")},
+		command{Name: "Save", Handle: echo("save")},
+		command{Name: "run", Handle: echo("run")},
+		command{Name: "help", Handle: echo("new help")},
+	)
+
+	tests := []struct {
+		name    string
+		handled string
+	}{
+		{name: "help", handled: "new help"},
+		{name: "HELP", handled: "new help"},
+		{name: "save", handled: "save"},
+		{name: "SaVe", handled: "save"},
+		{name: "run", handled: "run"},
+		{name: "py3"},
+		{name: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, found := r.lookup(test.name)
+			if found != (test.handled != "") {
+				t.Fatalf("expected found %v, got %v", test.handled != "", found)
+			}
+			if !found {
+				return
+			}
+			if res, _ := c.Handle(context.Background(), slack.Request{}, ""); res.Body != test.handled+":" {
+				t.Errorf("expected %s to handle it, got %s", test.handled, res.Body)
+			}
+		})
+	}
+
+	var names []string
+	for _, c := range r.commands {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, " ") != "help Save run" {
+		t.Errorf("expected each command once in registration order, got %v", names)
+	}
+}
+
+func TestRouterHelp(t *testing.T) {
+	r := newRouter(
+		command{Name: "history", Summary: "lists your recent runs"},
+		command{Name: "run", Args: "<name> [input]", Summary: "runs a saved snippet"},
+	)
+
+	want := "• `/code history` lists your recent runs\n" +
+		"• `/code run <name> [input]` runs a saved snippet\n"
+	if got := r.help("/code"); got != want {
+		t.Errorf("expected help\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestHelpListsEveryCommand(t *testing.T) {
+	l := &Listener{Languages: testLanguages}
+
+	res, err := l.handleHelp(context.Background(), slack.Request{AppCommand: "/code"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response slack.Response
+	if err = json.Unmarshal([]byte(res.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ResponseType == "in_channel" {
+		t.Errorf("expected the help to be private")
+	}
+	for _, c := range l.commands().commands {
+		if !strings.Contains(response.Text, "`/code "+c.Name) {
+			t.Errorf("expected %s in the help, got %q", c.Name, response.Text)
+		}
+	}
+}
+
+func TestCommandShadowsLanguageAlias(t *testing.T) {
+	languages := models.LanguageConfig{
+		"py3": testLanguages["py3"],
+		"hs":  {Name: "Haskell", ShortName: "hs", Aliases: []string{"history"}},
+	}
+	responder := &invokertest.Fake{}
+	l := &Listener{Languages: languages, Responder: responder}
+
+	for _, text := range []string{"history main = print 1", "HISTORY main = print 1"} {
+		res, err := l.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+			Body: url.Values{"command": {"/resl"}, "text": {text}, "user_id": {"U1"}, "channel_id": {"C1"}}.Encode(),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(res.Body, "History is not available") {
+			t.Errorf("expected %q to list the history, got %s", text, res.Body)
+		}
+	}
+
+	if len(responder.Calls()) != 0 {
+		t.Errorf("expected the command to win over the language alias")
+	}
+}
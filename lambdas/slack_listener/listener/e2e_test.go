@@ -0,0 +1,825 @@
+package listener_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/invoker/invokertest"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/slack/slacktest"
+	"github.com/stripedpajamas/resl/store"
+)
+
+const signingSecret = "listener-test-secret"
+
+var languages = models.LanguageConfig{
+	"py3": {Name: "Python", ShortName: "py3", Version: "3.7", Extension: "py", SnippetType: "python", Aliases: []string{"python"}, Placeholder: `print("Hello world")`},
+	"js":  {Name: "JavaScript", ShortName: "js", Version: "Node 14", Extension: "js", SnippetType: "javascript", Placeholder: `console.log("Hello world")`},
+}
+
+// harness is a listener wired to a fake slack and a fake responder, behind
+// the signature check as it is deployed
+type harness struct {
+	slack     *slacktest.Server
+	responder *invokertest.Fake
+	store     *store.File
+	handle    listener.HandlerFunc
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	previous, set := os.LookupEnv("SLACK_SIGNING_SECRET")
+	os.Setenv("SLACK_SIGNING_SECRET", signingSecret)
+
+	h := &harness{
+		slack:     slacktest.NewServer(signingSecret),
+		responder: &invokertest.Fake{},
+	}
+	h.slack.Token = "xoxb-test"
+
+	var err error
+	if h.store, err = store.OpenFile(""); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		h.slack.Close()
+		if set {
+			os.Setenv("SLACK_SIGNING_SECRET", previous)
+		} else {
+			os.Unsetenv("SLACK_SIGNING_SECRET")
+		}
+	})
+
+	l := &listener.Listener{
+		Languages: languages,
+		Slack:     h.slack.Client(),
+		Responder: h.responder,
+		Store:     h.store,
+	}
+	h.handle = listener.AuthorizeRequest(l.HandleRequest)
+
+	return h
+}
+
+// post posts a signed form to the listener as API Gateway delivers it
+func (h *harness) post(form url.Values) (events.APIGatewayProxyResponse, error) {
+	body := form.Encode()
+	return h.handle(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/run",
+		HTTPMethod: "POST",
+		Headers:    h.slack.SignedHeaders([]byte(body)),
+		Body:       body,
+	})
+}
+
+// send posts a form and expects the listener to accept it
+func (h *harness) send(t *testing.T, form url.Values) events.APIGatewayProxyResponse {
+	t.Helper()
+
+	res, err := h.post(form)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	return res
+}
+
+// sendEvent posts a signed Events API request, as the given delivery attempt
+func (h *harness) sendEvent(t *testing.T, request slack.EventRequest, retry int) (events.APIGatewayProxyResponse, error) {
+	t.Helper()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := h.slack.SignedHeaders(body)
+	headers["content-type"] = "application/json"
+	if retry > 0 {
+		headers["x-slack-retry-num"] = strconv.Itoa(retry)
+		headers["x-slack-retry-reason"] = "http_timeout"
+	}
+
+	return h.handle(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/events",
+		HTTPMethod: "POST",
+		Headers:    headers,
+		Body:       string(body),
+	})
+}
+
+func (h *harness) slashCommand(t *testing.T, text string) events.APIGatewayProxyResponse {
+	return h.commandAs(t, "U1", "C1", text)
+}
+
+// commandAs sends a slash command from a user in a channel of team T1
+func (h *harness) commandAs(t *testing.T, userID, channelID, text string) events.APIGatewayProxyResponse {
+	return h.send(t, h.slack.SlashCommandForm(slacktest.SlashCommand{
+		Text:      text,
+		UserID:    userID,
+		ChannelID: channelID,
+		TeamID:    "T1",
+		TriggerID: "trigger-1",
+	}))
+}
+
+// dispatched returns the requests handed to the responder
+func (h *harness) dispatched(t *testing.T) []models.CodeProcessRequest {
+	t.Helper()
+
+	var requests []models.CodeProcessRequest
+	for _, call := range h.responder.Calls() {
+		if !call.Async {
+			t.Errorf("expected the responder to be invoked asynchronously")
+		}
+		var request models.CodeProcessRequest
+		if err := json.Unmarshal(call.Payload, &request); err != nil {
+			t.Fatalf("responder payload is not a code process request: %s", err)
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+func responseBody(t *testing.T, res events.APIGatewayProxyResponse) slack.Response {
+	t.Helper()
+
+	var response slack.Response
+	if err := json.Unmarshal([]byte(res.Body), &response); err != nil {
+		t.Fatalf("response is not a slack message: %q", res.Body)
+	}
+	return response
+}
+
+func TestSlashCommandOpensModal(t *testing.T) {
+	h := newHarness(t)
+
+	h.slashCommand(t, "")
+
+	calls := h.slack.Calls("views.open")
+	if len(calls) != 1 {
+		t.Fatalf("expected one views.open call, got %d", len(calls))
+	}
+	if auth := calls[0].Header.Get("Authorization"); auth != "Bearer xoxb-test" {
+		t.Errorf("expected the bot token, got %q", auth)
+	}
+
+	modal := h.slack.ViewsOpened()[0]
+	if modal.TriggerID != "trigger-1" {
+		t.Errorf("expected the command's trigger id, got %q", modal.TriggerID)
+	}
+	if modal.View.Type != "modal" {
+		t.Errorf("expected a modal view, got %q", modal.View.Type)
+	}
+
+	var hasCode, hasLanguages bool
+	for _, block := range modal.View.Blocks {
+		switch block.BlockID {
+		case slack.CodeBlockName:
+			hasCode = true
+			if block.Element.MaxLength != slack.MaxInputLength {
+				t.Errorf("expected the code input to be limited to %d characters, got %d", slack.MaxInputLength, block.Element.MaxLength)
+			}
+		case slack.LanguageBlockName:
+			hasLanguages = true
+			if n := len(block.Element.Options); n != len(languages) {
+				t.Errorf("expected a dropdown of %d languages, got %d", len(languages), n)
+			}
+		}
+	}
+	if !hasCode || !hasLanguages {
+		t.Errorf("expected code and language inputs, got %+v", modal.View.Blocks)
+	}
+
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run before the modal is submitted")
+	}
+}
+
+func TestSlashCommandWithLanguageOpensModalForIt(t *testing.T) {
+	h := newHarness(t)
+
+	h.slashCommand(t, "python")
+
+	opened := h.slack.ViewsOpened()
+	if len(opened) != 1 {
+		t.Fatalf("expected one modal, got %d", len(opened))
+	}
+	if metadata := slack.ParseModalMetadata(opened[0].View.PrivateMetadata); metadata.Language != "py3" {
+		t.Errorf("expected a modal for py3, got %q", metadata.Language)
+	}
+	for _, block := range opened[0].View.Blocks {
+		if block.BlockID == slack.LanguageBlockName {
+			t.Errorf("expected no language dropdown in a modal for one language")
+		}
+	}
+}
+
+func TestSlashCommandDispatchesCode(t *testing.T) {
+	h := newHarness(t)
+
+	res := h.slashCommand(t, "py3 ```print(input())``` --stdin hi")
+
+	if response := responseBody(t, res); response.ResponseType != "in_channel" {
+		t.Errorf("expected the command to be shown in channel, got %+v", response)
+	}
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Code != "print(input())" || request.Stdin != "hi" || request.Props.ShortName != "py3" {
+		t.Errorf("unexpected code, input or language: %+v", request)
+	}
+	if request.ResponseURL != h.slack.ResponseURL("default") {
+		t.Errorf("expected the command's response url, got %q", request.ResponseURL)
+	}
+	if request.UserID != "U1" || request.ChannelID != "C1" || request.TeamID != "T1" {
+		t.Errorf("expected the command's user, channel and team, got %+v", request)
+	}
+	if request.Modal {
+		t.Errorf("expected a slash command run not to be marked as a modal run")
+	}
+
+	if len(h.slack.Calls("views.open")) != 0 {
+		t.Errorf("expected no modal when the command has code")
+	}
+}
+
+func TestSlashCommandUnsupportedLanguage(t *testing.T) {
+	h := newHarness(t)
+
+	res := h.slashCommand(t, "pyhton print(1)")
+
+	response := responseBody(t, res)
+	if response.ResponseType != "" || !strings.Contains(response.Text, "Did you mean `py3`?") {
+		t.Errorf("expected a private suggestion, got %+v", response)
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run")
+	}
+}
+
+func TestBadSignatureIsRejected(t *testing.T) {
+	h := newHarness(t)
+
+	body := h.slack.SlashCommandForm(slacktest.SlashCommand{Text: "py3 print(1)"}).Encode()
+	headers := h.slack.SignedHeaders([]byte(body))
+	headers["x-slack-signature"] = slacktest.Sign("some other secret", time.Now(), []byte(body))
+
+	res, err := h.handle(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/run",
+		HTTPMethod: "POST",
+		Headers:    headers,
+		Body:       body,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.StatusCode != 401 {
+		t.Errorf("expected status 401, got %d", res.StatusCode)
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run")
+	}
+}
+
+func TestModalSubmissionDispatchesCode(t *testing.T) {
+	h := newHarness(t)
+
+	form, err := slacktest.InteractionForm(map[string]interface{}{
+		"type": "view_submission",
+		"user": map[string]string{"id": "U1"},
+		"team": map[string]string{"id": "T1"},
+		"view": map[string]interface{}{
+			"private_metadata": `{"language":"js"}`,
+			"state": map[string]interface{}{
+				"values": map[string]interface{}{
+					slack.CodeBlockName: map[string]interface{}{
+						slack.CodeActionID: map[string]string{"type": "plain_text_input", "value": "console.log(require('fs').readFileSync(0, 'utf8'))"},
+					},
+					slack.StdinBlockName: map[string]interface{}{
+						slack.StdinActionID: map[string]string{"type": "plain_text_input", "value": "some input"},
+					},
+				},
+			},
+		},
+		"response_urls": []map[string]string{
+			{"action_id": "conversation_select_action", "channel_id": "C2", "response_url": h.slack.ResponseURL("modal")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := h.send(t, form)
+
+	if response := responseBody(t, res); response.ResponseAction != "clear" {
+		t.Errorf("expected the modal to be cleared, got %+v", response)
+	}
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Props.ShortName != "js" || request.Stdin != "some input" || !request.Modal {
+		t.Errorf("expected a js modal run with input, got %+v", request)
+	}
+	if request.ResponseURL != h.slack.ResponseURL("modal") || request.ChannelID != "C2" {
+		t.Errorf("expected the chosen conversation's response url, got %+v", request)
+	}
+}
+
+func TestMessageShortcutRunsTaggedCodeInThread(t *testing.T) {
+	h := newHarness(t)
+
+	form, err := slacktest.InteractionForm(slack.InteractionPayload{
+		Type:        slack.MessageActionType,
+		CallbackID:  slack.RunShortcutCallbackID,
+		TriggerID:   "trigger-2",
+		User:        slack.User{ID: "U1"},
+		Team:        slack.Team{ID: "T1"},
+		Channel:     slack.Channel{ID: "C1"},
+		ResponseURL: h.slack.ResponseURL("shortcut"),
+		Message: slack.Message{
+			Text: "try this:\n```python\nprint(1 &lt; 2)\n```",
+			TS:   "1600000000.000100",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.send(t, form)
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Props.ShortName != "py3" || request.Code != "print(1 < 2)\n" {
+		t.Errorf("expected the tagged python block, got %s %q", request.Props.ShortName, request.Code)
+	}
+	if request.ThreadTS != "1600000000.000100" || request.ChannelID != "C1" {
+		t.Errorf("expected the result in the message's thread, got %+v", request)
+	}
+	if len(h.slack.Calls("views.open")) != 0 {
+		t.Errorf("expected no modal when the language is known")
+	}
+}
+
+func TestMessageShortcutAsksForLanguage(t *testing.T) {
+	h := newHarness(t)
+
+	form, err := slacktest.InteractionForm(slack.InteractionPayload{
+		Type:        slack.MessageActionType,
+		CallbackID:  slack.RunShortcutCallbackID,
+		TriggerID:   "trigger-3",
+		User:        slack.User{ID: "U1"},
+		Team:        slack.Team{ID: "T1"},
+		Channel:     slack.Channel{ID: "C1"},
+		ResponseURL: h.slack.ResponseURL("shortcut"),
+		Message:     slack.Message{Text: "```print(1)```", TS: "1600000000.000200"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.send(t, form)
+
+	opened := h.slack.ViewsOpened()
+	if len(opened) != 1 {
+		t.Fatalf("expected one modal, got %d", len(opened))
+	}
+	if opened[0].TriggerID != "trigger-3" {
+		t.Errorf("expected the shortcut's trigger id, got %q", opened[0].TriggerID)
+	}
+	metadata := slack.ParseModalMetadata(opened[0].View.PrivateMetadata)
+	if metadata.ChannelID != "C1" || metadata.ThreadTS != "1600000000.000200" {
+		t.Errorf("expected the modal to remember the message's thread, got %+v", metadata)
+	}
+
+	var code string
+	for _, block := range opened[0].View.Blocks {
+		if block.BlockID == slack.CodeBlockName {
+			code = block.Element.InitialValue
+		}
+	}
+	if code != "print(1)" {
+		t.Errorf("expected the modal prefilled with the code, got %q", code)
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run before the modal is submitted")
+	}
+}
+
+func TestMessageShortcutRefusesCodeTooLongForModal(t *testing.T) {
+	h := newHarness(t)
+
+	form, err := slacktest.InteractionForm(slack.InteractionPayload{
+		Type:        slack.MessageActionType,
+		CallbackID:  slack.RunShortcutCallbackID,
+		TriggerID:   "trigger-4",
+		User:        slack.User{ID: "U1"},
+		Team:        slack.Team{ID: "T1"},
+		Channel:     slack.Channel{ID: "C1"},
+		ResponseURL: h.slack.ResponseURL("shortcut"),
+		Message:     slack.Message{Text: "```" + strings.Repeat("print(1)\n", 400) + "```", TS: "1600000000.000200"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.send(t, form)
+
+	if len(h.slack.Calls("views.open")) != 0 {
+		t.Errorf("expected no modal for code slack would reject")
+	}
+	posts := h.slack.Posts("shortcut")
+	if len(posts) != 1 || !strings.Contains(posts[0].Response.Text, "too long") || posts[0].Response.ResponseType == "in_channel" {
+		t.Errorf("expected a private note that the code is too long, got %+v", posts)
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run")
+	}
+}
+
+func mention(eventID string) slack.EventRequest {
+	return slack.EventRequest{
+		Type:    slack.EventCallbackType,
+		TeamID:  "T1",
+		EventID: eventID,
+		Event: slack.Event{
+			Type:    slack.AppMentionEvent,
+			User:    "U1",
+			Text:    "<@UBOT> py3 ```print(1)```",
+			Channel: "C1",
+			TS:      "1600000000.000300",
+		},
+	}
+}
+
+func TestEventRetriesRunOnce(t *testing.T) {
+	h := newHarness(t)
+
+	for retry := 0; retry < 3; retry++ {
+		if res, err := h.sendEvent(t, mention("Ev1"), retry); err != nil || res.StatusCode != 200 {
+			t.Fatalf("expected delivery %d to be accepted, got status %d: %v", retry, res.StatusCode, err)
+		}
+	}
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected the event to run once, got %d runs", len(requests))
+	}
+	if requests[0].Code != "print(1)" || requests[0].ThreadTS != "1600000000.000300" {
+		t.Errorf("expected the mention's code run in its thread, got %+v", requests[0])
+	}
+
+	if _, err := h.sendEvent(t, mention("Ev2"), 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(h.dispatched(t)) != 2 {
+		t.Errorf("expected another event to run")
+	}
+}
+
+func TestEventRetryRunsAfterFailure(t *testing.T) {
+	h := newHarness(t)
+	h.responder.Err = errors.New("responder is down")
+
+	if res, err := h.sendEvent(t, mention("Ev1"), 0); err == nil || res.StatusCode != 500 {
+		t.Fatalf("expected the failed delivery to be reported, got status %d", res.StatusCode)
+	}
+
+	h.responder.Err = nil
+	if res, err := h.sendEvent(t, mention("Ev1"), 1); err != nil || res.StatusCode != 200 {
+		t.Fatalf("expected the retry to be accepted, got status %d: %v", res.StatusCode, err)
+	}
+	if calls := len(h.responder.Calls()); calls != 2 {
+		t.Errorf("expected the retry to run the code again, got %d calls", calls)
+	}
+}
+
+// buttonForm is the press of a button on a result message in C1
+func (h *harness) buttonForm(t *testing.T, actionID string, run slack.RunAction) url.Values {
+	t.Helper()
+
+	value, err := json.Marshal(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := slacktest.InteractionForm(slack.InteractionPayload{
+		Type:        slack.BlockActionsType,
+		TriggerID:   "trigger-5",
+		User:        slack.User{ID: "U1"},
+		Team:        slack.Team{ID: "T1"},
+		Channel:     slack.Channel{ID: "C1"},
+		ResponseURL: h.slack.ResponseURL("button"),
+		Message:     slack.Message{ThreadTS: "1600000000.000400"},
+		Actions:     []slack.Action{{ActionID: actionID, Type: "button", Value: string(value)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func (h *harness) pressButton(t *testing.T, actionID string, run slack.RunAction) events.APIGatewayProxyResponse {
+	t.Helper()
+	return h.send(t, h.buttonForm(t, actionID, run))
+}
+
+func TestRerunButtonRunsCodeAgain(t *testing.T) {
+	h := newHarness(t)
+
+	h.pressButton(t, slack.RerunActionID, slack.RunAction{Language: "py3", Code: "print(input())", Stdin: "hi"})
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Code != "print(input())" || request.Stdin != "hi" || request.Props.ShortName != "py3" {
+		t.Errorf("expected the button's code, input and language, got %+v", request)
+	}
+	if request.ResponseURL != h.slack.ResponseURL("button") || request.ChannelID != "C1" || request.ThreadTS != "1600000000.000400" {
+		t.Errorf("expected the result where the button was pressed, got %+v", request)
+	}
+	if request.UserID != "U1" || !request.Modal {
+		t.Errorf("expected a run by the presser that echoes its code, got %+v", request)
+	}
+	if len(h.slack.Calls("views.open")) != 0 {
+		t.Errorf("expected no modal for a re-run")
+	}
+}
+
+func TestEditRerunButtonOpensPrefilledModal(t *testing.T) {
+	h := newHarness(t)
+
+	h.pressButton(t, slack.EditRerunActionID, slack.RunAction{Language: "js", Code: "console.log(1)", Stdin: "hi"})
+
+	opened := h.slack.ViewsOpened()
+	if len(opened) != 1 {
+		t.Fatalf("expected one modal, got %d", len(opened))
+	}
+	if opened[0].TriggerID != "trigger-5" {
+		t.Errorf("expected the button's trigger id, got %q", opened[0].TriggerID)
+	}
+
+	metadata := slack.ParseModalMetadata(opened[0].View.PrivateMetadata)
+	if metadata.ChannelID != "C1" || metadata.ThreadTS != "1600000000.000400" {
+		t.Errorf("expected the modal to remember where the button was, got %+v", metadata)
+	}
+
+	inputs := map[string]*slack.Element{}
+	for _, block := range opened[0].View.Blocks {
+		inputs[block.BlockID] = block.Element
+	}
+	if code := inputs[slack.CodeBlockName]; code == nil || code.InitialValue != "console.log(1)" {
+		t.Errorf("expected the code prefilled, got %+v", code)
+	}
+	if stdin := inputs[slack.StdinBlockName]; stdin == nil || stdin.InitialValue != "hi" {
+		t.Errorf("expected the input prefilled, got %+v", stdin)
+	}
+	if language := inputs[slack.LanguageBlockName]; language == nil || language.InitialOption == nil || language.InitialOption.Value != "js" {
+		t.Errorf("expected js preselected, got %+v", language)
+	}
+
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run before the modal is submitted")
+	}
+}
+
+// saveRun keeps a run in the harness's store as the responder would
+func (h *harness) saveRun(t *testing.T, teamID, userID, code string) store.Run {
+	t.Helper()
+
+	run := store.NewRun(models.CodeProcessRequest{
+		Code:      code,
+		Stdin:     "saved input",
+		Props:     languages["py3"],
+		UserID:    userID,
+		ChannelID: "C1",
+		TeamID:    teamID,
+	}, models.CodeOutput{Run: &models.ExecutionResult{Stdout: "1\n"}})
+	if err := store.Record(context.Background(), h.store, run); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestHistoryListsOwnRunsPrivately(t *testing.T) {
+	h := newHarness(t)
+
+	first := h.saveRun(t, "T1", "U1", "print(1)")
+	second := h.saveRun(t, "T1", "U1", "print(2)")
+	h.saveRun(t, "T1", "U2", "print('someone else')")
+	h.saveRun(t, "T2", "U1", "print('another workspace')")
+
+	response := responseBody(t, h.slashCommand(t, "history"))
+	if response.ResponseType == "in_channel" {
+		t.Errorf("expected the history to be visible only to the user")
+	}
+
+	var values []string
+	for _, block := range response.Blocks {
+		if block.Type != "actions" {
+			continue
+		}
+		for _, element := range block.Elements {
+			button := element.(map[string]interface{})
+			if button["action_id"] == slack.RerunActionID {
+				values = append(values, button["value"].(string))
+			}
+		}
+	}
+	if len(values) != 2 {
+		t.Fatalf("expected re-run buttons for the user's two runs, got %d", len(values))
+	}
+	for i, run := range []store.Run{second, first} {
+		action, err := slack.ParseRunAction(values[i])
+		if err != nil || action.RunID != run.ID || action.Code != "" {
+			t.Errorf("expected run %d to be looked up by id, got %q", i, values[i])
+		}
+	}
+	if strings.Contains(response.Text, "someone else") || strings.Contains(response.Text, "another workspace") {
+		t.Errorf("expected only the user's runs, got %q", response.Text)
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run")
+	}
+}
+
+func TestHistoryRerunLooksUpRun(t *testing.T) {
+	h := newHarness(t)
+	run := h.saveRun(t, "T1", "U1", "print(input())")
+
+	h.pressButton(t, slack.RerunActionID, slack.RunAction{RunID: run.ID})
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	if request := requests[0]; request.Code != "print(input())" || request.Stdin != "saved input" || request.Props.ShortName != "py3" {
+		t.Errorf("expected the saved run's code, input and language, got %+v", request)
+	}
+}
+
+func TestHistoryRejectsOthersRuns(t *testing.T) {
+	h := newHarness(t)
+
+	tests := []struct {
+		name string
+		run  store.Run
+	}{
+		{name: "another user's run", run: h.saveRun(t, "T1", "U2", "print('secret')")},
+		{name: "another workspace's run", run: h.saveRun(t, "T2", "U1", "print('secret')")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, actionID := range []string{slack.RerunActionID, slack.EditRerunActionID} {
+				res, err := h.post(h.buttonForm(t, actionID, slack.RunAction{RunID: test.run.ID}))
+				if err == nil || res.StatusCode != 400 {
+					t.Errorf("expected %s to be refused, got status %d", actionID, res.StatusCode)
+				}
+			}
+		})
+	}
+
+	if len(h.responder.Calls()) != 0 || len(h.slack.Calls("views.open")) != 0 {
+		t.Errorf("expected nothing run or shown")
+	}
+}
+
+// privateReply returns the text of a reply only the user sees
+func privateReply(t *testing.T, res events.APIGatewayProxyResponse) string {
+	t.Helper()
+
+	response := responseBody(t, res)
+	if response.ResponseType == "in_channel" {
+		t.Errorf("expected a private reply, got %+v", response)
+	}
+	return response.Text
+}
+
+func TestSnippetSaveAndRun(t *testing.T) {
+	h := newHarness(t)
+
+	if text := privateReply(t, h.slashCommand(t, "save Fib py3 ```print(input())```")); !strings.Contains(text, "Saved `fib`") {
+		t.Errorf("expected the snippet to be saved under a lower cased name, got %q", text)
+	}
+	snippet, err := h.store.FindSnippet(context.Background(), "T1", store.UserNamespace("U1"), "fib")
+	if err != nil || snippet.Code != "print(input())" || snippet.Language != "py3" || snippet.CreatedBy != "U1" {
+		t.Fatalf("expected the snippet in the user's namespace, got %+v, %v", snippet, err)
+	}
+
+	res := h.slashCommand(t, "run fib `5`")
+	if response := responseBody(t, res); response.ResponseType != "in_channel" {
+		t.Errorf("expected the run to be shown in channel, got %+v", response)
+	}
+
+	requests := h.dispatched(t)
+	if len(requests) != 1 {
+		t.Fatalf("expected one request for the responder, got %d", len(requests))
+	}
+	request := requests[0]
+	if request.Code != "print(input())" || request.Stdin != "5" || request.Props.ShortName != "py3" {
+		t.Errorf("expected the snippet run with the input, got %+v", request)
+	}
+	if !request.Modal {
+		t.Errorf("expected the result to echo the snippet's code")
+	}
+}
+
+func TestSharedAndPersonalSnippets(t *testing.T) {
+	h := newHarness(t)
+
+	h.commandAs(t, "U2", "C1", "save --shared fib js console.log('shared')")
+
+	// with no snippet of their own, everyone in the channel runs the shared one
+	h.commandAs(t, "U1", "C1", "run fib")
+	// until they save their own under the same name
+	h.commandAs(t, "U1", "C1", "save fib py3 print('mine')")
+	h.commandAs(t, "U1", "C1", "run fib")
+	h.commandAs(t, "U2", "C1", "run fib")
+
+	var runs []string
+	for _, request := range h.dispatched(t) {
+		runs = append(runs, request.UserID+" "+request.Code)
+	}
+	want := "U1 console.log('shared'), U1 print('mine'), U2 console.log('shared')"
+	if strings.Join(runs, ", ") != want {
+		t.Errorf("expected runs %s, got %s", want, strings.Join(runs, ", "))
+	}
+
+	// the shared snippet belongs to its channel only
+	if text := privateReply(t, h.commandAs(t, "U2", "C2", "run fib")); !strings.Contains(text, "no snippet named `fib`") {
+		t.Errorf("expected no shared snippet in another channel, got %q", text)
+	}
+
+	text := privateReply(t, h.commandAs(t, "U1", "C1", "snippets"))
+	own, shared := strings.Index(text, "*Your snippets*"), strings.Index(text, "*Shared in this channel*")
+	if own < 0 || shared < own || strings.Count(text, "`fib`") != 2 {
+		t.Errorf("expected the user's and the channel's fib listed apart, got %q", text)
+	}
+
+	h.commandAs(t, "U1", "C1", "delete --shared fib")
+	if _, err := h.store.FindSnippet(context.Background(), "T1", store.ChannelNamespace("C1"), "fib"); err != store.ErrNotFound {
+		t.Errorf("expected the shared snippet deleted, got %v", err)
+	}
+	if _, err := h.store.FindSnippet(context.Background(), "T1", store.UserNamespace("U1"), "fib"); err != nil {
+		t.Errorf("expected the user's own snippet kept, got %v", err)
+	}
+}
+
+func TestDeleteMissingSnippet(t *testing.T) {
+	h := newHarness(t)
+
+	h.slashCommand(t, "save fib py3 print(1)")
+
+	for _, text := range []string{"delete nope", "delete --shared fib"} {
+		if reply := privateReply(t, h.slashCommand(t, text)); !strings.Contains(reply, "There is no snippet named") {
+			t.Errorf("expected %q to find nothing, got %q", text, reply)
+		}
+	}
+	if _, err := h.store.FindSnippet(context.Background(), "T1", store.UserNamespace("U1"), "fib"); err != nil {
+		t.Errorf("expected the user's snippet kept, got %v", err)
+	}
+}
+
+func TestLangsListsLanguages(t *testing.T) {
+	h := newHarness(t)
+
+	text := privateReply(t, h.send(t, h.slack.SlashCommandForm(slacktest.SlashCommand{
+		Command: "/code",
+		Text:    "LANGS",
+		UserID:  "U1",
+		TeamID:  "T1",
+	})))
+
+	for _, want := range []string{"`js` JavaScript (Node 14)", "`py3` Python (3.7), also `python`", "`/code <language> <code>`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in the list, got %q", want, text)
+		}
+	}
+	if len(h.responder.Calls()) != 0 {
+		t.Errorf("expected nothing to run")
+	}
+}
@@ -0,0 +1,150 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// the mentions of the app at the start of an app_mention's text
+var leadingMentions = regexp.MustCompile(`^(\s*<@[^>]+>)+\s*`)
+
+// handles an Events API request, answering slack's url verification and
+// running the code in app mentions
+func (l *Listener) handleEvent(ctx context.Context, request events.APIGatewayProxyRequest, body []byte) (events.APIGatewayProxyResponse, error) {
+	var eventRequest slack.EventRequest
+	if err := json.Unmarshal(body, &eventRequest); err != nil {
+		return createErrorResponse(400, err, "Error while parsing event")
+	}
+
+	if eventRequest.Type == slack.URLVerificationType {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+			Body:       eventRequest.Challenge,
+			Headers: map[string]string{
+				"Content-Type": "text/plain",
+			},
+		}, nil
+	}
+
+	event := eventRequest.Event
+	if eventRequest.Type != slack.EventCallbackType || event.Type != slack.AppMentionEvent || event.BotID != "" {
+		log.Printf("Ignoring %s event\n", event.Type)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	if !l.claimEvent(ctx, request, eventRequest.EventID) {
+		log.Printf("Ignoring repeated delivery of event %s\n", eventRequest.EventID)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	if err := l.handleAppMention(ctx, eventRequest); err != nil {
+		l.releaseEvent(ctx, eventRequest.EventID)
+		return createErrorResponse(500, err, "Error while handling app mention")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+	}, nil
+}
+
+// reports whether this delivery of the event is the one to handle. Slack
+// delivers an event again when it isn't answered within a few seconds, so
+// with a store only the first delivery to claim the event id runs it. Without
+// one every retry is dropped, trusting that the first delivery got through
+func (l *Listener) claimEvent(ctx context.Context, request events.APIGatewayProxyRequest, eventID string) bool {
+	if l.Store == nil || eventID == "" {
+		return request.Headers["x-slack-retry-num"] == ""
+	}
+
+	claimed, err := l.Store.ClaimEvent(ctx, eventID)
+	if err != nil {
+		log.Printf("Error while claiming event %s, handling it anyway: %s\n", eventID, err.Error())
+		return true
+	}
+	return claimed
+}
+
+// forgets a claimed event that failed, so that slack's retry of it runs
+func (l *Listener) releaseEvent(ctx context.Context, eventID string) {
+	if l.Store == nil || eventID == "" {
+		return
+	}
+
+	if err := l.Store.ReleaseEvent(ctx, eventID); err != nil {
+		log.Printf("Error while releasing event %s: %s\n", eventID, err.Error())
+	}
+}
+
+// runs the code in an app mention, given as a tagged fenced block
+// (@resl ```py ...```) or after a language (@resl py ...), replying in thread
+func (l *Listener) handleAppMention(ctx context.Context, eventRequest slack.EventRequest) error {
+	event := eventRequest.Event
+	text := unescape(leadingMentions.ReplaceAllString(event.Text, ""))
+
+	threadTS := event.ThreadTS
+	if threadTS == "" {
+		threadTS = event.TS
+	}
+
+	code, languages := l.codeFromMessage(text)
+	if len(languages) == 0 {
+		language, rest := parseText(text)
+		if props, found := l.Languages.Lookup(language); found {
+			code, languages = stripBackticks(strings.TrimSpace(rest)), []models.LanguageProperties{props}
+		}
+	}
+
+	var reply string
+	switch {
+	case strings.TrimSpace(code) == "":
+		reply = "Mention me with some code, e.g. @resl py ```print(\"Hello world\")```"
+	case len(languages) == 0:
+		reply = "Which language is that? Tag the code block, e.g. ```py, or start with the language, e.g. @resl py"
+	case len(languages) > 1:
+		names := make([]string, len(languages))
+		for i, props := range languages {
+			names[i] = props.ShortName
+		}
+		reply = "That could be " + strings.Join(names, " or ") + ", tag the code block with one of them"
+	}
+
+	if reply != "" {
+		client, err := l.slackFor(ctx, eventRequest.TeamID, eventRequest.EnterpriseID)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.PostMessage(ctx, slack.ChatMessage{
+			Channel:  event.Channel,
+			Text:     reply,
+			ThreadTS: threadTS,
+		})
+		return err
+	}
+
+	log.Printf("Parsed Code: %s\n", code)
+	log.Printf("Parsed Language: %s\n", languages[0].ShortName)
+
+	return l.dispatch(ctx, models.CodeProcessRequest{
+		Code:         code,
+		Props:        languages[0],
+		UserID:       event.User,
+		ChannelID:    event.Channel,
+		TeamID:       eventRequest.TeamID,
+		EnterpriseID: eventRequest.EnterpriseID,
+		ThreadTS:     threadTS,
+		// nothing in the thread shows who ran it
+		Modal: true,
+	})
+}
@@ -0,0 +1,63 @@
+package listener
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// historyCommand lists the user's recent runs in place of running code
+const historyCommand = "history"
+
+// historyLimit is how many runs the history lists
+const historyLimit = 10
+
+// lists the user's recent runs in a message only they can see
+func (l *Listener) handleHistory(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	if l.Store == nil {
+		return privateText("History is not available, runs are not being saved")
+	}
+
+	runs, err := l.Store.RecentRuns(ctx, body.TeamID, body.UserID, historyLimit)
+	if err != nil {
+		return createErrorResponse(500, err, "Error while loading history")
+	}
+
+	entries := make([]slack.HistoryEntry, len(runs))
+	for i, run := range runs {
+		props, found := l.Languages[run.Language]
+		if !found {
+			props.Name = run.Language
+		}
+
+		entries[i] = slack.HistoryEntry{
+			RunID:  run.ID,
+			Props:  props,
+			Code:   run.Code,
+			Output: run.Output,
+			RanAt:  run.CreatedAt,
+		}
+	}
+
+	return messageResponse(slack.HistoryMessage(entries))
+}
+
+// returns the saved run a history button refers to
+func (l *Listener) savedRun(ctx context.Context, teamID, userID, runID string) (slack.RunAction, error) {
+	if l.Store == nil {
+		return slack.RunAction{}, errors.New("Runs are not being saved")
+	}
+
+	run, err := l.Store.FindRun(ctx, teamID, userID, runID)
+	if err != nil {
+		return slack.RunAction{}, err
+	}
+
+	return slack.RunAction{
+		Language: run.Language,
+		Code:     run.Code,
+		Stdin:    run.Stdin,
+	}, nil
+}
@@ -0,0 +1,133 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// langsCommand lists the languages code can be run in
+const langsCommand = "langs"
+
+// lists the configured languages
+func (l *Listener) handleLangs(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	return messageResponse(slack.LanguagesMessage(l.Languages, commandName(body)))
+}
+
+// editDistance is the number of single character insertions, deletions and
+// substitutions that turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// closestLanguages returns the short names of the languages whose short name,
+// display name or alias is nearest to the given name, if any are near enough
+// to be what was meant
+func (l *Listener) closestLanguages(name string) []string {
+	name = strings.ToLower(name)
+
+	// allow about one typo for every two characters
+	best := len([]rune(name)) / 2
+	if best < 1 {
+		best = 1
+	}
+
+	var closest []string
+	for _, props := range slack.SortedLanguages(l.Languages) {
+		distance := editDistance(name, strings.ToLower(props.ShortName))
+		for _, other := range append([]string{props.Name}, props.Aliases...) {
+			if d := editDistance(name, strings.ToLower(other)); d < distance {
+				distance = d
+			}
+		}
+
+		switch {
+		case distance < best:
+			best = distance
+			closest = []string{props.ShortName}
+		case distance == best:
+			closest = append(closest, props.ShortName)
+		}
+	}
+
+	return closest
+}
+
+// parseLanguage splits the language off the start of text, named by its short
+// name or an alias (py3 ...) or by the tag of the fenced block the code starts
+// with (```python ...```). A tag after a named language is dropped from the code
+func (l *Listener) parseLanguage(body slack.Request, text string) (models.LanguageProperties, string, error) {
+	language, code := parseText(text)
+
+	if props, found := l.Languages.Lookup(language); found {
+		if tag, rest, tagged := splitFenceTag(code); tagged && len(l.languagesForTag(tag)) > 0 {
+			code = rest
+		}
+		return props, code, nil
+	}
+
+	tag, code, tagged := splitFenceTag(text)
+	if !tagged && strings.HasPrefix(strings.TrimSpace(text), "```") {
+		return models.LanguageProperties{}, "", fmt.Errorf("Which language is that? Start with the language, e.g. `%s py3 <code>`, or tag the code block, e.g. ```py3", commandName(body))
+	}
+	if !tagged {
+		return models.LanguageProperties{}, "", errors.New(l.unsupportedLanguage(body, language))
+	}
+
+	languages := l.languagesForTag(tag)
+	switch len(languages) {
+	case 0:
+		return models.LanguageProperties{}, "", errors.New(l.unsupportedLanguage(body, tag))
+	case 1:
+		return languages[0], code, nil
+	}
+
+	names := make([]string, len(languages))
+	for i, props := range languages {
+		names[i] = props.ShortName
+	}
+	return models.LanguageProperties{}, "", fmt.Errorf("`%s` could be `%s`, start with the one you mean, e.g. `%s %s <code>`", tag, strings.Join(names, "` or `"), commandName(body), names[0])
+}
+
+// unsupportedLanguage explains that there is no such language, suggesting the
+// ones that were probably meant
+func (l *Listener) unsupportedLanguage(body slack.Request, language string) string {
+	text := fmt.Sprintf("Language `%s` is not supported, see `%s %s` for the ones that are", language, commandName(body), langsCommand)
+
+	if closest := l.closestLanguages(language); len(closest) > 0 {
+		text += ". Did you mean `" + strings.Join(closest, "` or `") + "`?"
+	}
+	return text
+}
@@ -0,0 +1,63 @@
+package listener
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"py3", "py3", 0},
+		{"", "js", 2},
+		{"rust", "", 4},
+		{"pyton", "python", 1},
+		{"pyhton", "python", 2},
+		{"kitten", "sitting", 3},
+		{"é", "e", 1},
+	}
+
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestClosestLanguages(t *testing.T) {
+	l := &Listener{Languages: models.LanguageConfig{
+		"py2": {Name: "Python", ShortName: "py2", Version: "2.7"},
+		"py3": {Name: "Python", ShortName: "py3", Version: "3.7", Aliases: []string{"python3"}},
+		"js":  {Name: "JavaScript", ShortName: "js", Aliases: []string{"node"}},
+		"go":  {Name: "Go", ShortName: "go", Aliases: []string{"golang"}},
+	}}
+
+	tests := []struct {
+		name    string
+		input   string
+		closest []string
+	}{
+		{name: "exact alias", input: "python3", closest: []string{"py3"}},
+		{name: "alias ignoring case", input: "NODE", closest: []string{"js"}},
+		{name: "one edit typo", input: "golan", closest: []string{"go"}},
+		{name: "one edit from a short name", input: "jss", closest: []string{"js"}},
+		{name: "tie", input: "py", closest: []string{"py2", "py3"}},
+		{name: "display name shared by a tie", input: "pyhton", closest: []string{"py2", "py3"}},
+		{name: "no close match", input: "cobol"},
+		{name: "empty", input: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			closest := l.closestLanguages(test.input)
+			if strings.Join(closest, " ") != strings.Join(test.closest, " ") {
+				t.Errorf("closestLanguages(%q) = %v; want %v", test.input, closest, test.closest)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package listener
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/gorilla/schema"
+	"github.com/stripedpajamas/resl/invoker"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/store"
+)
+
+// Listener handles slash commands and modal submissions from slack, handing
+// the parsed code off to the responder
+type Listener struct {
+	Languages models.LanguageConfig
+	// Slack calls slack, with each workspace's own token when there are
+	// Installations
+	Slack *slack.Client
+	// Installations, when set, holds the bot token of each workspace the app
+	// was installed in through OAuth. Other workspaces are turned away
+	Installations slack.InstallationStore
+	// Store, when set, holds each user's past runs
+	Store store.Store
+	// Responder is handed each serialized models.CodeProcessRequest without
+	// waiting for the code to run
+	Responder invoker.Invoker
+}
+
+var decoder = newDecoder()
+
+func newDecoder() *schema.Decoder {
+	d := schema.NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	return d
+}
+
+// returns the client that calls slack on behalf of a workspace
+func (l *Listener) slackFor(ctx context.Context, teamID, enterpriseID string) (*slack.Client, error) {
+	return slack.ClientForTeam(ctx, l.Slack, l.Installations, teamID, enterpriseID)
+}
+
+func createErrorResponse(code int, err error, message string) (events.APIGatewayProxyResponse, error) {
+	if message == "" {
+		message = "Error found"
+	}
+	log.Printf("%s: %s\n", message, err.Error())
+	return events.APIGatewayProxyResponse{
+		StatusCode: code,
+	}, err
+}
+
+// messageResponse answers slack with a message only the user sees
+func messageResponse(message slack.Response) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return createErrorResponse(500, err, "Failed to serialize message for Slack")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// privateText answers slack with text only the user sees
+func privateText(text string) (events.APIGatewayProxyResponse, error) {
+	return messageResponse(slack.Response{Text: text})
+}
+
+func parseText(text string) (string, string) {
+	trimmedText := strings.Trim(text, " ")
+	spaceIdx := strings.IndexRune(trimmedText, ' ')
+
+	if spaceIdx < 0 {
+		return trimmedText, ""
+	}
+
+	return trimmedText[0:spaceIdx], trimmedText[spaceIdx+1:]
+}
+
+// cleans up slack's auto replacements
+func unescape(text string) string {
+	text = strings.ReplaceAll(text, "&amp;", "&")
+	text = strings.ReplaceAll(text, "&lt;", "<")
+	text = strings.ReplaceAll(text, "&gt;", ">")
+	return text
+}
+
+// program input follows the code as a second fenced block or after --stdin
+var stdinBlocks = regexp.MustCompile("(?s)^\\s*```(.*?)```\\s*```(.*?)```\\s*$")
+var stdinDelimiter = regexp.MustCompile(`\s--stdin(\s|$)`)
+
+// removes the single or triple backticks wrapping a code block
+func stripBackticks(code string) string {
+	i := 0
+	j := len(code) - 1
+
+	for i <= j && code[i] == code[j] && code[i] == '`' {
+		i++
+		j--
+	}
+
+	if i == 1 || i == 3 {
+		code = code[i : j+1]
+	}
+
+	return code
+}
+
+// splits the program input from the code, given either as a second fenced
+// block (```code``` ```input```) or after a delimiter (code --stdin input)
+func splitStdin(text string) (string, string) {
+	if blocks := stdinBlocks.FindStringSubmatch(text); blocks != nil {
+		return blocks[1], blocks[2]
+	}
+
+	if loc := findStdinDelimiter(text); loc != nil {
+		return strings.TrimSpace(text[:loc[0]]), stripBackticks(strings.TrimSpace(text[loc[1]:]))
+	}
+
+	return text, ""
+}
+
+// finds the first --stdin that isn't inside a ``` fence, so code can use the
+// flag itself
+func findStdinDelimiter(text string) []int {
+	for _, loc := range stdinDelimiter.FindAllStringIndex(text, -1) {
+		if strings.Count(text[:loc[0]], "```")%2 == 0 {
+			return loc
+		}
+	}
+	return nil
+}
+
+func (l *Listener) getCodePayloadFromRequestBody(requestBody slack.Request) (models.CodeProcessRequest, error) {
+	log.Printf("Request Body: %+v\n", requestBody)
+
+	if requestBody.Text == "" {
+		return models.CodeProcessRequest{
+			ResponseURL: requestBody.ResponseURL,
+			Code:        "",
+			Props:       models.LanguageProperties{},
+		}, nil
+	}
+
+	props, code, err := l.parseLanguage(requestBody, requestBody.Text)
+	if err != nil {
+		return models.CodeProcessRequest{}, err
+	}
+
+	code = unescape(code)
+
+	// input from the modal arrives separately; slash commands include it in the text
+	stdin := requestBody.Stdin
+	if stdin == "" {
+		code, stdin = splitStdin(code)
+	}
+
+	// remove backticks from code block
+	code = stripBackticks(code)
+
+	log.Printf("Parsed Code: %s\n", code)
+	log.Printf("Parsed Language: %s\n", props.ShortName)
+	log.Printf("Parsed Stdin: %s\n", stdin)
+
+	// json stringify the result for the execution lambda
+	return models.CodeProcessRequest{
+		ResponseURL:  requestBody.ResponseURL,
+		Code:         code,
+		Props:        props,
+		UserID:       requestBody.UserID,
+		TeamID:       requestBody.TeamID,
+		EnterpriseID: requestBody.EnterpriseID,
+		ChannelID:    requestBody.ChannelID,
+		ThreadTS:     requestBody.ThreadTS,
+		Stdin:        stdin,
+	}, nil
+}
+
+func createRequestBodyFromModalPayload(payload slack.ModalRequest) (slack.Request, error) {
+	formData := payload.View.State.Values
+
+	codeElementVal, ok := formData[slack.CodeBlockName]
+	if !ok {
+		return slack.Request{}, errors.New("Code block not found")
+	}
+
+	metadata := slack.ParseModalMetadata(payload.View.PrivateMetadata)
+	language := metadata.Language
+
+	languageElementVal, ok := formData[slack.LanguageBlockName]
+	if language == "" && !ok {
+		return slack.Request{}, errors.New("No language provided")
+	} else if ok && language == "" {
+		languageInputVal, ok := languageElementVal[slack.LanguageActionID]
+		if !ok {
+			return slack.Request{}, errors.New("Language action not found")
+		}
+
+		languageInputJSON, err := json.Marshal(languageInputVal)
+		if err != nil {
+			return slack.Request{}, err
+		}
+
+		var languageInput slack.StaticSelectElement
+		err = json.Unmarshal([]byte(languageInputJSON), &languageInput)
+		if err != nil {
+			return slack.Request{}, err
+		}
+
+		language = languageInput.SelectedOption.Value
+	}
+
+	codeinputVal, ok := codeElementVal[slack.CodeActionID]
+	if !ok {
+		return slack.Request{}, errors.New("Code action not found")
+	}
+
+	inputJSON, err := json.Marshal(codeinputVal)
+	if err != nil {
+		return slack.Request{}, err
+	}
+
+	var codeInput slack.InputElement
+	err = json.Unmarshal([]byte(inputJSON), &codeInput)
+	if err != nil {
+		return slack.Request{}, err
+	}
+
+	if codeInput.Value == "" {
+		return slack.Request{}, errors.New("No code present in the modal input")
+	}
+
+	var stdinInput slack.InputElement
+	if stdinElementVal, ok := formData[slack.StdinBlockName]; ok {
+		stdinJSON, err := json.Marshal(stdinElementVal[slack.StdinActionID])
+		if err != nil {
+			return slack.Request{}, err
+		}
+
+		err = json.Unmarshal([]byte(stdinJSON), &stdinInput)
+		if err != nil {
+			return slack.Request{}, err
+		}
+	}
+
+	if payload.ResponseURLS == nil || len(payload.ResponseURLS) == 0 {
+		return slack.Request{}, errors.New("No response urls available in modal request")
+	}
+
+	// only reply in the thread the modal came from if the user kept its channel
+	threadTS := ""
+	if metadata.ChannelID == payload.ResponseURLS[0].ChannelID {
+		threadTS = metadata.ThreadTS
+	}
+
+	request := slack.Request{
+		Text:        fmt.Sprintf("%s %s", language, codeInput.Value),
+		ResponseURL: payload.ResponseURLS[0].URL,
+		ChannelID:   payload.ResponseURLS[0].ChannelID,
+		TriggerID:   payload.TriggerID,
+		UserID:      payload.User.ID,
+		Stdin:       stdinInput.Value,
+		ThreadTS:    threadTS,
+	}
+	if payload.Team != nil {
+		request.TeamID = payload.Team.ID
+	}
+	if payload.Enterprise != nil {
+		request.EnterpriseID = payload.Enterprise.ID
+	}
+
+	return request, nil
+}
+
+// returns the raw request body, which API Gateway base64 encodes unless it is text
+func decodeBody(request events.APIGatewayProxyRequest) ([]byte, error) {
+	if !request.IsBase64Encoded {
+		return []byte(request.Body), nil
+	}
+	return base64.StdEncoding.DecodeString(request.Body)
+}
+
+func parseFormRequest(body []byte) (slack.Request, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return slack.Request{}, err
+	}
+
+	log.Printf("Request form %s\n", form)
+
+	var payload slack.Request
+	err = decoder.Decode(&payload, form)
+	if err != nil {
+		return slack.Request{}, err
+	}
+
+	return payload, nil
+}
+
+// hands the request to the responder without waiting for it to run
+func (l *Listener) dispatch(ctx context.Context, request models.CodeProcessRequest) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return l.Responder.InvokeAsync(ctx, payload)
+}
+
+// tells the user privately that the code is too long for the modal, since
+// slack refuses to open one prefilled with it
+func (l *Listener) refuseLongPrefill(ctx context.Context, responseURL string) (events.APIGatewayProxyResponse, error) {
+	text := fmt.Sprintf("That code is too long to edit in the modal, which holds at most %d characters", slack.MaxInputLength)
+	if err := l.Slack.SendPrivateResponse(ctx, responseURL, text); err != nil {
+		return createErrorResponse(500, err, "Failed to respond to action")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+	}, nil
+}
+
+// handles the buttons on result messages, running the code again or opening
+// the modal with it for editing
+func (l *Listener) handleBlockActions(ctx context.Context, payload slack.InteractionPayload) (events.APIGatewayProxyResponse, error) {
+	if len(payload.Actions) == 0 {
+		return createErrorResponse(400, errors.New("No actions in payload"), "Error while processing block actions")
+	}
+
+	action := payload.Actions[0]
+	log.Printf("Block action: %s\n", action.ActionID)
+
+	run, err := slack.ParseRunAction(action.Value)
+	if err != nil {
+		return createErrorResponse(400, err, "Error while parsing action value")
+	}
+
+	if run.RunID != "" {
+		if run, err = l.savedRun(ctx, payload.Team.ID, payload.User.ID, run.RunID); err != nil {
+			return createErrorResponse(400, err, "Error while loading the saved run")
+		}
+	}
+
+	props, found := l.Languages[run.Language]
+	if !found {
+		return createErrorResponse(400, errors.New("language not supported"), "Error while processing block actions")
+	}
+
+	switch action.ActionID {
+	case slack.RerunActionID:
+		err = l.dispatch(ctx, models.CodeProcessRequest{
+			ResponseURL:  payload.ResponseURL,
+			Code:         run.Code,
+			Props:        props,
+			UserID:       payload.User.ID,
+			ChannelID:    payload.Channel.ID,
+			TeamID:       payload.Team.ID,
+			EnterpriseID: payload.EnterpriseID(),
+			ThreadTS:     payload.Message.ThreadTS,
+			// nothing in the channel shows the code, so the result echoes it
+			Modal: true,
+			Stdin: run.Stdin,
+		})
+		if err != nil {
+			return createErrorResponse(500, err, "Error while invoking the code process lambda")
+		}
+	case slack.EditRerunActionID:
+		prefill := run.Prefill()
+		prefill.ChannelID = payload.Channel.ID
+		prefill.ThreadTS = payload.Message.ThreadTS
+		if !prefill.Fits() {
+			return l.refuseLongPrefill(ctx, payload.ResponseURL)
+		}
+
+		client, err := l.slackFor(ctx, payload.Team.ID, payload.EnterpriseID())
+		if err != nil {
+			return createErrorResponse(500, err, "Failed to find the workspace's installation")
+		}
+
+		if err = client.OpenModal(ctx, payload.TriggerID, slack.GenerateRESLModal(l.Languages, "", prefill)); err != nil {
+			return createErrorResponse(500, err, "Failed to send modal")
+		}
+	default:
+		log.Printf("Ignoring unknown action %s\n", action.ActionID)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+	}, nil
+}
+
+// HandleRequest parses a request from slack and either opens the resl modal
+// or dispatches the code to be run
+func (l *Listener) HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	rawBody, err := decodeBody(request)
+	if err != nil {
+		return createErrorResponse(500, err, "Error while parsing request")
+	}
+
+	// the Events API posts json, everything else is form encoded
+	if strings.HasPrefix(request.Headers["content-type"], "application/json") {
+		return l.handleEvent(ctx, request, rawBody)
+	}
+
+	body, err := parseFormRequest(rawBody)
+	if err != nil {
+		return createErrorResponse(500, err, "Error while parsing request")
+	}
+
+	log.Printf("Parsed Body: %+v\n", body)
+
+	if body.ModalPayload == "" {
+		name, args := parseText(body.Text)
+		if c, found := l.commands().lookup(name); found {
+			return c.Handle(ctx, body, args)
+		}
+	}
+
+	var modalBody slack.ModalRequest
+	isModal := false
+
+	if body.ModalPayload != "" {
+		var interaction slack.InteractionPayload
+		if err := json.Unmarshal([]byte(body.ModalPayload), &interaction); err != nil {
+			return createErrorResponse(500, err, "Error while parsing interaction payload")
+		}
+		switch interaction.Type {
+		case slack.BlockActionsType:
+			return l.handleBlockActions(ctx, interaction)
+		case slack.MessageActionType:
+			return l.handleMessageAction(ctx, interaction)
+		}
+
+		isModal = true
+		err := json.Unmarshal([]byte(body.ModalPayload), &modalBody)
+		if err != nil {
+			return createErrorResponse(500, err, "Error while parsing modal body")
+		}
+
+		body, err = createRequestBodyFromModalPayload(modalBody)
+		if err != nil {
+			return createErrorResponse(400, err, "Error while processing modal body")
+		}
+	}
+
+	codeProcessRequest, err := l.getCodePayloadFromRequestBody(body)
+	if err != nil {
+		log.Printf("Error while parsing language and code from request: %s\n", err.Error())
+		responseBody, serializationErr := slack.PrivateAcknowledgement(err.Error())
+
+		if serializationErr != nil {
+			return createErrorResponse(500, err, "Failed to serialize parsing error for Slack")
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+			Body:       string(responseBody),
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+		}, nil
+	}
+
+	// fire a modal back since no code was there and modal is not alreay present
+	if !isModal && codeProcessRequest.Code == "" {
+		client, err := l.slackFor(ctx, body.TeamID, body.EnterpriseID)
+		if err != nil {
+			return createErrorResponse(500, err, "Failed to find the workspace's installation")
+		}
+
+		err = client.SendModal(ctx, body.TriggerID, l.Languages, codeProcessRequest.Props.ShortName)
+
+		if err != nil {
+			return createErrorResponse(500, err, "Failed to send modal")
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	codeProcessRequest.Modal = isModal
+
+	if err = l.dispatch(ctx, codeProcessRequest); err != nil {
+		return createErrorResponse(500, err, "Error while invoking the code process lambda")
+	}
+
+	var res []byte
+
+	if isModal {
+		res, err = slack.ClearModal()
+	} else {
+		res, err = slack.PublicAcknowledgement()
+	}
+
+	if err != nil {
+		return createErrorResponse(500, err, "")
+	}
+
+	log.Printf("Responding to slack: %s", res)
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
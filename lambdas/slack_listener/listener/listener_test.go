@@ -0,0 +1,191 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+var testLanguages = models.LanguageConfig{
+	"py3": {Name: "Python", ShortName: "py3", Version: "3.7", Extension: "py", SnippetType: "python", Aliases: []string{"python"}, Placeholder: `print("Hello world")`},
+	"js":  {Name: "JavaScript", ShortName: "js", Version: "Node 14", Extension: "js", SnippetType: "javascript", Aliases: []string{"node"}, Placeholder: `console.log("Hello world")`},
+}
+
+func TestSplitStdin(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		code  string
+		stdin string
+	}{
+		{
+			name: "no stdin",
+			text: "print(input())",
+			code: "print(input())",
+		},
+		{
+			name: "fenced code without stdin",
+			text: "```print(input())```",
+			code: "```print(input())```",
+		},
+		{
+			name:  "second fence",
+			text:  "```print(input())``` ```hello```",
+			code:  "print(input())",
+			stdin: "hello",
+		},
+		{
+			name:  "second fence on its own line",
+			text:  "```print(input())```\n```hello\nworld```",
+			code:  "print(input())",
+			stdin: "hello\nworld",
+		},
+		{
+			name:  "delimiter",
+			text:  "print(input()) --stdin hello",
+			code:  "print(input())",
+			stdin: "hello",
+		},
+		{
+			name:  "delimiter after a fence",
+			text:  "```print(input())``` --stdin `hello`",
+			code:  "```print(input())```",
+			stdin: "hello",
+		},
+		{
+			name:  "delimiter at the end",
+			text:  "print(input()) --stdin",
+			code:  "print(input())",
+			stdin: "",
+		},
+		{
+			name: "delimiter inside a fence",
+			text: "```parser.add_argument(' --stdin ')```",
+			code: "```parser.add_argument(' --stdin ')```",
+		},
+		{
+			name:  "delimiter inside and after a fence",
+			text:  "```echo --stdin x``` --stdin hello",
+			code:  "```echo --stdin x```",
+			stdin: "hello",
+		},
+		{
+			name: "delimiter inside an unclosed fence",
+			text: "```echo --stdin x",
+			code: "```echo --stdin x",
+		},
+		{
+			name: "flag without a space before it",
+			text: "run(--stdin)",
+			code: "run(--stdin)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, stdin := splitStdin(test.text)
+			if code != test.code || stdin != test.stdin {
+				t.Errorf("splitStdin(%q) = %q, %q; want %q, %q", test.text, code, stdin, test.code, test.stdin)
+			}
+		})
+	}
+}
+
+func TestGetCodePayloadFromRequestBody(t *testing.T) {
+	l := &Listener{Languages: testLanguages}
+
+	tests := []struct {
+		name string
+		text string
+		// modalStdin is input given separately, as the modal does
+		modalStdin string
+		language   string
+		code       string
+		stdin      string
+	}{
+		{
+			name:     "plain code",
+			text:     "py3 print(1)",
+			language: "py3",
+			code:     "print(1)",
+		},
+		{
+			name:     "fenced code",
+			text:     "py3 ```print(1)```",
+			language: "py3",
+			code:     "print(1)",
+		},
+		{
+			name:     "fenced code with stdin fence",
+			text:     "py3 ```print(input())``` ```hi```",
+			language: "py3",
+			code:     "print(input())",
+			stdin:    "hi",
+		},
+		{
+			name:     "fenced code with stdin delimiter",
+			text:     "py3 ```print(input())``` --stdin hi",
+			language: "py3",
+			code:     "print(input())",
+			stdin:    "hi",
+		},
+		{
+			name:     "delimiter inside the fence",
+			text:     "js ```console.log('a --stdin b')```",
+			language: "js",
+			code:     "console.log('a --stdin b')",
+		},
+		{
+			name:       "modal stdin",
+			text:       "py3 print(input()) --stdin ignored",
+			modalStdin: "from modal",
+			language:   "py3",
+			code:       "print(input()) --stdin ignored",
+			stdin:      "from modal",
+		},
+		{
+			name:     "alias",
+			text:     "Python print(1)",
+			language: "py3",
+			code:     "print(1)",
+		},
+		{
+			name:     "fence tag",
+			text:     "```node\nconsole.log(1)```",
+			language: "js",
+			code:     "console.log(1)",
+		},
+		{
+			name:     "escaped characters",
+			text:     "py3 print(1 &lt; 2 &amp;&amp; 3 &gt; 2)",
+			language: "py3",
+			code:     "print(1 < 2 && 3 > 2)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := l.getCodePayloadFromRequestBody(slack.Request{Text: test.text, Stdin: test.modalStdin})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if request.Props.ShortName != test.language || request.Code != test.code || request.Stdin != test.stdin {
+				t.Errorf("got %s %q stdin %q; want %s %q stdin %q", request.Props.ShortName, request.Code, request.Stdin, test.language, test.code, test.stdin)
+			}
+		})
+	}
+}
+
+func TestGetCodePayloadFromRequestBodyUnsupported(t *testing.T) {
+	l := &Listener{Languages: testLanguages}
+
+	_, err := l.getCodePayloadFromRequestBody(slack.Request{Text: "pyhton print(1)"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown language")
+	}
+	want := "Language `pyhton` is not supported, see `/resl langs` for the ones that are. Did you mean `py3`?"
+	if err.Error() != want {
+		t.Errorf("got %q; want %q", err.Error(), want)
+	}
+}
@@ -1,10 +1,9 @@
-package main
+package listener
 
 import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -16,10 +15,13 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
-type lambdaHandlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+// HandlerFunc is the signature of an API Gateway lambda handler
+type HandlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 
-func authorizeRequest(next lambdaHandlerFunc) lambdaHandlerFunc {
-	return lambdaHandlerFunc(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// AuthorizeRequest rejects requests that are not signed with the slack
+// signing secret or that are too old to be anything but a replay
+func AuthorizeRequest(next HandlerFunc) HandlerFunc {
+	return HandlerFunc(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		timestamp := request.Headers["x-slack-request-timestamp"]
 		signature := request.Headers["x-slack-signature"]
 
@@ -39,7 +41,7 @@ func authorizeRequest(next lambdaHandlerFunc) lambdaHandlerFunc {
 			}, nil
 		}
 
-		bodyStr, err := base64.StdEncoding.DecodeString(request.Body)
+		bodyStr, err := decodeBody(request)
 		if err != nil {
 			log.Printf("Failed to parse body: %s\n", err.Error())
 			return events.APIGatewayProxyResponse{
@@ -0,0 +1,225 @@
+package listener
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// DefaultScopes are the bot scopes resl asks for at install
+var DefaultScopes = []string{"commands", "chat:write", "files:write", "files:read", "app_mentions:read"}
+
+// stateTTL is how long an install link stays valid
+const stateTTL = 10 * time.Minute
+
+// stateCookie holds the nonce in the state of the install the browser started
+const stateCookie = "resl_oauth_state"
+
+// OAuth serves the link that installs the app in a workspace and the
+// redirect slack sends back to, saving each workspace's bot token
+type OAuth struct {
+	ClientID     string
+	ClientSecret string
+	// Scopes defaults to DefaultScopes
+	Scopes []string
+	// RedirectURL must be one of the app's redirect urls; slack uses the
+	// first one when it is empty
+	RedirectURL string
+	// StateSecret signs the state that ties a callback to an install started here
+	StateSecret   string
+	Slack         *slack.Client
+	Installations slack.InstallationStore
+}
+
+// NewOAuthFromEnv returns the install flow configured by the SLACK_CLIENT_ID,
+// SLACK_CLIENT_SECRET, SLACK_REDIRECT_URL and SLACK_STATE_SECRET environment
+// variables, or nil when there is no client id
+func NewOAuthFromEnv(installations slack.InstallationStore) (*OAuth, error) {
+	if os.Getenv("SLACK_CLIENT_ID") == "" {
+		return nil, nil
+	}
+
+	// anyone who knows the secret can forge a state, so it can't be one that
+	// is shared with slack or other apps
+	stateSecret := os.Getenv("SLACK_STATE_SECRET")
+	if stateSecret == "" {
+		return nil, errors.New("SLACK_STATE_SECRET must be set to install the app with SLACK_CLIENT_ID")
+	}
+
+	return &OAuth{
+		ClientID:      os.Getenv("SLACK_CLIENT_ID"),
+		ClientSecret:  os.Getenv("SLACK_CLIENT_SECRET"),
+		RedirectURL:   os.Getenv("SLACK_REDIRECT_URL"),
+		StateSecret:   stateSecret,
+		Slack:         slack.NewClientFromEnv(),
+		Installations: installations,
+	}, nil
+}
+
+func (o *OAuth) sign(timestamp, nonce string) string {
+	hash := hmac.New(sha256.New, []byte(o.StateSecret))
+	hash.Write([]byte(timestamp + "." + nonce))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// returns a state that expires after stateTTL, carrying a nonce that the
+// browser starting the install also keeps in a cookie
+func (o *OAuth) newState() (state, nonce string, err error) {
+	random := make([]byte, 16)
+	if _, err = rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	nonce = hex.EncodeToString(random)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return timestamp + "." + nonce + "." + o.sign(timestamp, nonce), nonce, nil
+}
+
+// checks that the state was made here, hasn't expired and belongs to the
+// install started by the browser with the nonce, so nobody can finish their
+// own install in someone else's browser
+func (o *OAuth) checkState(state, nonce string) error {
+	parts := strings.Split(state, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed state")
+	}
+
+	timestamp, stateNonce, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(o.sign(timestamp, stateNonce))) {
+		return errors.New("state signature does not match")
+	}
+	if nonce == "" || !hmac.Equal([]byte(stateNonce), []byte(nonce)) {
+		return errors.New("state was not issued to this browser")
+	}
+
+	issued, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return err
+	}
+	if time.Since(time.Unix(issued, 0)) > stateTTL {
+		return errors.New("state has expired")
+	}
+
+	return nil
+}
+
+// returns the Set-Cookie header value that keeps the nonce for the callback,
+// or clears it when maxAge is negative
+func nonceCookie(nonce string, maxAge int) string {
+	cookie := &http.Cookie{
+		Name:     stateCookie,
+		Value:    nonce,
+		Path:     "/oauth/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		// slack redirects back with a top level navigation, which lax
+		// cookies are sent with
+		SameSite: http.SameSiteLaxMode,
+	}
+	return cookie.String()
+}
+
+// reads the nonce cookie from API Gateway's lowercased headers
+func requestNonce(headers map[string]string) string {
+	request := http.Request{Header: http.Header{"Cookie": {headers["cookie"]}}}
+	cookie, err := request.Cookie(stateCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// a small html page for the browser going through the install
+func oauthPage(status int, text string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Body:       "<!DOCTYPE html><html><body><p>" + html.EscapeString(text) + "</p></body></html>",
+		Headers: map[string]string{
+			"Content-Type": "text/html; charset=utf-8",
+		},
+	}
+}
+
+// HandleRequest redirects /oauth/install to slack's install page and saves
+// the installation slack sends back to /oauth/callback
+func (o *OAuth) HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case strings.HasSuffix(request.Path, "/install"):
+		scopes := o.Scopes
+		if len(scopes) == 0 {
+			scopes = DefaultScopes
+		}
+
+		state, nonce, err := o.newState()
+		if err != nil {
+			return createErrorResponse(500, err, "Error while starting the install")
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 302,
+			Headers: map[string]string{
+				"Location":   slack.InstallURL(o.ClientID, scopes, o.RedirectURL, state),
+				"Set-Cookie": nonceCookie(nonce, int(stateTTL/time.Second)),
+			},
+		}, nil
+	case strings.HasSuffix(request.Path, "/callback"):
+		res, err := o.callback(ctx, request.QueryStringParameters, requestNonce(request.Headers))
+		if err == nil {
+			// the state can't be used again once the install is over
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers["Set-Cookie"] = nonceCookie("", -1)
+		}
+		return res, err
+	default:
+		return oauthPage(404, "Not found"), nil
+	}
+}
+
+func (o *OAuth) callback(ctx context.Context, query map[string]string, nonce string) (events.APIGatewayProxyResponse, error) {
+	if reason := query["error"]; reason != "" {
+		log.Printf("Install was not completed: %s\n", reason)
+		return oauthPage(200, "resl was not installed."), nil
+	}
+
+	if err := o.checkState(query["state"], nonce); err != nil {
+		log.Printf("Rejecting install callback: %s\n", err.Error())
+		return oauthPage(400, "This install link is invalid or has expired, please start again."), nil
+	}
+
+	if query["code"] == "" {
+		return oauthPage(400, "Slack did not send an authorization code."), nil
+	}
+
+	installation, err := o.Slack.OAuthV2Access(ctx, o.ClientID, o.ClientSecret, query["code"], o.RedirectURL)
+	if err != nil {
+		return createErrorResponse(500, err, "Error while exchanging the authorization code")
+	}
+
+	if err = o.Installations.SaveInstallation(ctx, installation); err != nil {
+		return createErrorResponse(500, err, "Error while saving the installation")
+	}
+
+	log.Printf("Installed in team %s (enterprise %s)\n", installation.TeamID, installation.EnterpriseID)
+
+	name := installation.TeamName
+	if name == "" {
+		name = "your workspace"
+	}
+	return oauthPage(200, "resl is installed in "+name+". You can close this page."), nil
+}
@@ -0,0 +1,154 @@
+package listener_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/lambdas/slack_listener/listener"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/slack/slacktest"
+)
+
+func newOAuth(t *testing.T) (*listener.OAuth, *slack.MemoryInstallationStore) {
+	t.Helper()
+
+	server := slacktest.NewServer(signingSecret)
+	t.Cleanup(server.Close)
+
+	installations := &slack.MemoryInstallationStore{}
+	return &listener.OAuth{
+		ClientID:      "C123",
+		ClientSecret:  "client-secret",
+		StateSecret:   "state-secret",
+		Slack:         server.Client(),
+		Installations: installations,
+	}, installations
+}
+
+// install starts the install flow and returns the state slack is sent and
+// the cookie the browser is given
+func install(t *testing.T, oauth *listener.OAuth) (string, string) {
+	t.Helper()
+
+	res, err := oauth.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+		Path:       "/oauth/install",
+		HTTPMethod: "GET",
+	})
+	if err != nil || res.StatusCode != 302 {
+		t.Fatalf("expected a redirect to slack, got status %d: %v", res.StatusCode, err)
+	}
+
+	location, err := url.Parse(res.Headers["Location"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Query().Get("client_id") != "C123" {
+		t.Errorf("expected the app's client id, got %s", location)
+	}
+
+	cookie := (&http.Response{Header: http.Header{"Set-Cookie": {res.Headers["Set-Cookie"]}}}).Cookies()
+	if len(cookie) != 1 || !cookie[0].HttpOnly || !cookie[0].Secure {
+		t.Fatalf("expected a secure nonce cookie, got %q", res.Headers["Set-Cookie"])
+	}
+	return location.Query().Get("state"), cookie[0].Name + "=" + cookie[0].Value
+}
+
+func callback(t *testing.T, oauth *listener.OAuth, state, cookie string) events.APIGatewayProxyResponse {
+	t.Helper()
+
+	res, err := oauth.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+		Path:                  "/oauth/callback",
+		HTTPMethod:            "GET",
+		Headers:               map[string]string{"cookie": cookie},
+		QueryStringParameters: map[string]string{"code": "code-1", "state": state},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return res
+}
+
+func TestOAuthInstall(t *testing.T) {
+	oauth, installations := newOAuth(t)
+
+	state, cookie := install(t, oauth)
+	if res := callback(t, oauth, state, cookie); res.StatusCode != 200 {
+		t.Fatalf("expected the install to succeed, got status %d: %s", res.StatusCode, res.Body)
+	}
+
+	installation, err := installations.FindInstallation(context.Background(), "TFAKE", "")
+	if err != nil {
+		t.Fatalf("expected the installation to be saved: %s", err)
+	}
+	if installation.BotToken != "xoxb-fake" {
+		t.Errorf("expected the workspace's bot token, got %q", installation.BotToken)
+	}
+}
+
+func TestOAuthRejectsForeignState(t *testing.T) {
+	oauth, installations := newOAuth(t)
+
+	// a state signed with any other secret, e.g. slack's signing secret
+	other := *oauth
+	other.StateSecret = signingSecret
+	state, cookie := install(t, &other)
+
+	if res := callback(t, oauth, state, cookie); res.StatusCode != 400 {
+		t.Errorf("expected the state to be rejected, got status %d", res.StatusCode)
+	}
+	if _, err := installations.FindInstallation(context.Background(), "TFAKE", ""); err != slack.ErrInstallationNotFound {
+		t.Errorf("expected nothing installed, got %v", err)
+	}
+}
+
+func TestOAuthRejectsStateFromAnotherBrowser(t *testing.T) {
+	oauth, installations := newOAuth(t)
+
+	// someone starts an install and gets another browser to finish it
+	state, _ := install(t, oauth)
+	_, otherCookie := install(t, oauth)
+
+	for _, cookie := range []string{"", otherCookie} {
+		if res := callback(t, oauth, state, cookie); res.StatusCode != 400 {
+			t.Errorf("expected the state to be rejected with cookie %q, got status %d", cookie, res.StatusCode)
+		}
+	}
+	if _, err := installations.FindInstallation(context.Background(), "TFAKE", ""); err != slack.ErrInstallationNotFound {
+		t.Errorf("expected nothing installed, got %v", err)
+	}
+}
+
+func TestNewOAuthFromEnvNeedsStateSecret(t *testing.T) {
+	for _, name := range []string{"SLACK_CLIENT_ID", "SLACK_STATE_SECRET"} {
+		previous, set := os.LookupEnv(name)
+		name := name
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	os.Unsetenv("SLACK_CLIENT_ID")
+	if oauth, err := listener.NewOAuthFromEnv(nil); oauth != nil || err != nil {
+		t.Errorf("expected no install flow without a client id, got %v, %v", oauth, err)
+	}
+
+	os.Setenv("SLACK_CLIENT_ID", "C123")
+	os.Unsetenv("SLACK_STATE_SECRET")
+	if _, err := listener.NewOAuthFromEnv(nil); err == nil {
+		t.Errorf("expected an error without a state secret")
+	}
+
+	os.Setenv("SLACK_STATE_SECRET", "state-secret")
+	oauth, err := listener.NewOAuthFromEnv(nil)
+	if err != nil || oauth.StateSecret != "state-secret" {
+		t.Errorf("expected the install flow to use SLACK_STATE_SECRET, got %v, %v", oauth, err)
+	}
+}
@@ -0,0 +1,159 @@
+package listener
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+var fencedBlock = regexp.MustCompile("(?s)```(.*?)```")
+var fenceTag = regexp.MustCompile(`^[\w+#.-]+$`)
+
+// languagesForTag returns the languages a fence tag like ```python could
+// mean, preferring a short name or alias over snippet types, extensions and names
+func (l *Listener) languagesForTag(tag string) []models.LanguageProperties {
+	tag = strings.ToLower(tag)
+	if props, found := l.Languages.Lookup(tag); found {
+		return []models.LanguageProperties{props}
+	}
+
+	var matches []models.LanguageProperties
+	for _, props := range l.Languages {
+		if tag == props.SnippetType || tag == props.Extension || tag == strings.ToLower(props.Name) {
+			matches = append(matches, props)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ShortName < matches[j].ShortName
+	})
+
+	return matches
+}
+
+// splits the tag off text that starts with a tagged fenced block, leaving the
+// block without it: ```python\nprint(1)``` is python and ```print(1)```
+func splitFenceTag(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	newline := strings.IndexByte(text, '\n')
+	if !strings.HasPrefix(text, "```") || newline < 0 {
+		return "", text, false
+	}
+
+	tag := strings.TrimSpace(text[3:newline])
+	if !fenceTag.MatchString(tag) {
+		return "", text, false
+	}
+
+	return tag, "```" + text[newline+1:], true
+}
+
+// pulls the code out of a message's first fenced block, or the whole message
+// if it has none, along with the languages its fence tag could mean
+func (l *Listener) codeFromMessage(text string) (string, []models.LanguageProperties) {
+	block := fencedBlock.FindStringSubmatch(text)
+	if block == nil {
+		return stripBackticks(strings.TrimSpace(text)), nil
+	}
+
+	code := block[1]
+	newline := strings.IndexByte(code, '\n')
+	if newline < 0 {
+		return code, nil
+	}
+
+	// the first line is only a tag if it names a language, otherwise it's code
+	tag := strings.TrimSpace(code[:newline])
+	if !fenceTag.MatchString(tag) {
+		return code, nil
+	}
+
+	languages := l.languagesForTag(tag)
+	if len(languages) == 0 {
+		return code, nil
+	}
+
+	return code[newline+1:], languages
+}
+
+// handles the "Run this code" message shortcut, running the message's code in
+// its thread or asking for the language with the modal if it can't be told
+func (l *Listener) handleMessageAction(ctx context.Context, payload slack.InteractionPayload) (events.APIGatewayProxyResponse, error) {
+	if payload.CallbackID != slack.RunShortcutCallbackID {
+		log.Printf("Ignoring unknown shortcut %s\n", payload.CallbackID)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	code, languages := l.codeFromMessage(unescape(payload.Message.Text))
+	log.Printf("Parsed Code: %s\n", code)
+
+	if strings.TrimSpace(code) == "" {
+		if err := l.Slack.SendPrivateResponse(ctx, payload.ResponseURL, "No code found in that message"); err != nil {
+			return createErrorResponse(500, err, "Failed to respond to shortcut")
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	// reply in the message's thread, starting one if it isn't in one
+	threadTS := payload.Message.ThreadTS
+	if threadTS == "" {
+		threadTS = payload.Message.TS
+	}
+
+	if len(languages) == 1 {
+		err := l.dispatch(ctx, models.CodeProcessRequest{
+			ResponseURL:  payload.ResponseURL,
+			Code:         code,
+			Props:        languages[0],
+			UserID:       payload.User.ID,
+			ChannelID:    payload.Channel.ID,
+			TeamID:       payload.Team.ID,
+			EnterpriseID: payload.EnterpriseID(),
+			ThreadTS:     threadTS,
+			// the message shows the code but not who ran it
+			Modal: true,
+		})
+		if err != nil {
+			return createErrorResponse(500, err, "Error while invoking the code process lambda")
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	prefill := slack.ModalPrefill{
+		Code:      code,
+		ChannelID: payload.Channel.ID,
+		ThreadTS:  threadTS,
+	}
+	if len(languages) > 0 {
+		prefill.Language = languages[0].ShortName
+	}
+	if !prefill.Fits() {
+		return l.refuseLongPrefill(ctx, payload.ResponseURL)
+	}
+
+	client, err := l.slackFor(ctx, payload.Team.ID, payload.EnterpriseID())
+	if err != nil {
+		return createErrorResponse(500, err, "Failed to find the workspace's installation")
+	}
+
+	if err = client.OpenModal(ctx, payload.TriggerID, slack.GenerateRESLModal(l.Languages, "", prefill)); err != nil {
+		return createErrorResponse(500, err, "Failed to send modal")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/store"
+)
+
+// subcommands that manage named snippets
+const (
+	saveCommand     = "save"
+	runCommand      = "run"
+	snippetsCommand = "snippets"
+	deleteCommand   = "delete"
+)
+
+// sharedFlag puts a snippet in the channel's namespace instead of the user's
+const sharedFlag = "--shared"
+
+// snippet names are lower cased before they are checked
+var snippetName = regexp.MustCompile(`^[a-z0-9_.-]{1,64}$`)
+
+const noStoreText = "Snippets are not available, nothing is being saved"
+
+// splits a leading --shared off the arguments
+func parseShared(args string) (bool, string) {
+	if first, rest := parseText(args); first == sharedFlag {
+		return true, rest
+	}
+	return false, args
+}
+
+func parseSnippetName(args string) (string, string) {
+	name, rest := parseText(args)
+	return strings.ToLower(name), rest
+}
+
+// returns the namespace a snippet command works in
+func snippetNamespace(shared bool, body slack.Request) string {
+	if shared {
+		return store.ChannelNamespace(body.ChannelID)
+	}
+	return store.UserNamespace(body.UserID)
+}
+
+// saves code under a name: save [--shared] <name> <language> <code>
+func (l *Listener) handleSave(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	if l.Store == nil {
+		return privateText(noStoreText)
+	}
+
+	usage := fmt.Sprintf("Usage: `%s save [%s] <name> <language> <code>`", commandName(body), sharedFlag)
+
+	shared, args := parseShared(args)
+	name, rest := parseSnippetName(args)
+	if !snippetName.MatchString(name) {
+		return privateText("Snippet names can only use letters, numbers, dots, dashes and underscores. " + usage)
+	}
+
+	props, code, err := l.parseLanguage(body, rest)
+	if err != nil {
+		return privateText(err.Error() + "\n" + usage)
+	}
+
+	code = stripBackticks(unescape(code))
+	if strings.TrimSpace(code) == "" {
+		return privateText("There is no code to save. " + usage)
+	}
+
+	err = l.Store.SaveSnippet(ctx, store.Snippet{
+		TeamID:    body.TeamID,
+		Namespace: snippetNamespace(shared, body),
+		Name:      name,
+		Language:  props.ShortName,
+		Code:      code,
+		CreatedBy: body.UserID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return createErrorResponse(500, err, "Error while saving snippet")
+	}
+
+	where := ""
+	if shared {
+		where = " for everyone in this channel"
+	}
+	return privateText(fmt.Sprintf("Saved `%s` · %s%s. Run it with `%s run %s`", name, slack.LanguageLabel(props), where, commandName(body), name))
+}
+
+// finds a snippet by name, looking through the user's own snippets before the
+// ones shared in the channel
+func (l *Listener) findSnippet(ctx context.Context, body slack.Request, name string) (store.Snippet, error) {
+	snippet, err := l.Store.FindSnippet(ctx, body.TeamID, store.UserNamespace(body.UserID), name)
+	if !errors.Is(err, store.ErrNotFound) || body.ChannelID == "" {
+		return snippet, err
+	}
+	return l.Store.FindSnippet(ctx, body.TeamID, store.ChannelNamespace(body.ChannelID), name)
+}
+
+// runs a saved snippet: run <name> [stdin]
+func (l *Listener) handleRun(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	if l.Store == nil {
+		return privateText(noStoreText)
+	}
+
+	name, stdin := parseSnippetName(args)
+	if name == "" {
+		return privateText(fmt.Sprintf("Usage: `%s run <name> [input]`", commandName(body)))
+	}
+
+	snippet, err := l.findSnippet(ctx, body, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return privateText(fmt.Sprintf("There is no snippet named `%s`, see `%s %s`", name, commandName(body), snippetsCommand))
+	}
+	if err != nil {
+		return createErrorResponse(500, err, "Error while loading snippet")
+	}
+
+	props, found := l.Languages[snippet.Language]
+	if !found {
+		return privateText(fmt.Sprintf("`%s` is written in %s, which is no longer supported", name, snippet.Language))
+	}
+
+	err = l.dispatch(ctx, models.CodeProcessRequest{
+		ResponseURL:  body.ResponseURL,
+		Code:         snippet.Code,
+		Props:        props,
+		UserID:       body.UserID,
+		ChannelID:    body.ChannelID,
+		TeamID:       body.TeamID,
+		EnterpriseID: body.EnterpriseID,
+		// nothing in the channel shows the code, so the result echoes it
+		Modal: true,
+		Stdin: stripBackticks(unescape(stdin)),
+	})
+	if err != nil {
+		return createErrorResponse(500, err, "Error while invoking the code process lambda")
+	}
+
+	res, err := slack.PublicAcknowledgement()
+	if err != nil {
+		return createErrorResponse(500, err, "")
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// writes a titled list of snippets, or nothing when there are none
+func writeSnippets(b *strings.Builder, title string, snippets []store.Snippet, languages models.LanguageConfig) {
+	if len(snippets) == 0 {
+		return
+	}
+
+	b.WriteString("*" + title + "*\n")
+	for _, snippet := range snippets {
+		label := snippet.Language
+		if props, found := languages[snippet.Language]; found {
+			label = slack.LanguageLabel(props)
+		}
+		b.WriteString(fmt.Sprintf("• `%s` · %s\n", snippet.Name, label))
+	}
+}
+
+// lists the user's snippets and the ones shared in the channel
+func (l *Listener) handleSnippets(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	if l.Store == nil {
+		return privateText(noStoreText)
+	}
+
+	own, err := l.Store.ListSnippets(ctx, body.TeamID, store.UserNamespace(body.UserID))
+	if err != nil {
+		return createErrorResponse(500, err, "Error while listing snippets")
+	}
+
+	var shared []store.Snippet
+	if body.ChannelID != "" {
+		shared, err = l.Store.ListSnippets(ctx, body.TeamID, store.ChannelNamespace(body.ChannelID))
+		if err != nil {
+			return createErrorResponse(500, err, "Error while listing snippets")
+		}
+	}
+
+	if len(own) == 0 && len(shared) == 0 {
+		return privateText(fmt.Sprintf("There are no snippets yet. Save one with `%s save [%s] <name> <language> <code>`", commandName(body), sharedFlag))
+	}
+
+	var b strings.Builder
+	writeSnippets(&b, "Your snippets", own, l.Languages)
+	writeSnippets(&b, "Shared in this channel", shared, l.Languages)
+	b.WriteString(fmt.Sprintf("Run one with `%s run <name> [input]`", commandName(body)))
+
+	return privateText(b.String())
+}
+
+// deletes a snippet: delete [--shared] <name>
+func (l *Listener) handleDelete(ctx context.Context, body slack.Request, args string) (events.APIGatewayProxyResponse, error) {
+	if l.Store == nil {
+		return privateText(noStoreText)
+	}
+
+	shared, args := parseShared(args)
+	name, _ := parseSnippetName(args)
+	if name == "" {
+		return privateText(fmt.Sprintf("Usage: `%s delete [%s] <name>`", commandName(body), sharedFlag))
+	}
+
+	err := l.Store.DeleteSnippet(ctx, body.TeamID, snippetNamespace(shared, body), name)
+	if errors.Is(err, store.ErrNotFound) {
+		return privateText(fmt.Sprintf("There is no snippet named `%s` to delete", name))
+	}
+	if err != nil {
+		return createErrorResponse(500, err, "Error while deleting snippet")
+	}
+
+	return privateText(fmt.Sprintf("Deleted `%s`", name))
+}
@@ -3,94 +3,78 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
-	"strings"
+	"strconv"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	lambdaClient "github.com/aws/aws-sdk-go/service/lambda"
 
+	"github.com/stripedpajamas/resl/invoker"
+	"github.com/stripedpajamas/resl/lambdas/slack_responder/responder"
 	"github.com/stripedpajamas/resl/models"
 	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/store"
 )
 
-type CodeOutput struct {
-	Output string `json:"output"`
-}
-
-// replaces backticks with \`
-func escapeString(s string) string {
-	return strings.ReplaceAll(s, "`", "\\`")
-}
-
-// wraps a string in ```<string>```
-func wrapString(request models.CodeProcessRequest, s string) string {
-	var b strings.Builder
-	if request.Modal {
-		b.WriteString("<@" + request.UserID + ">\n")
-		b.WriteString("```")
-		b.WriteString(request.Code)
-		b.WriteString("```")
-		b.WriteString("\n")
+var r responder.Responder
+
+// handles either a direct invocation from the listener or a batch of
+// requests delivered through the queue invoker
+func handleEvent(ctx context.Context, event json.RawMessage) error {
+	var batch events.SQSEvent
+	if err := json.Unmarshal(event, &batch); err == nil && len(batch.Records) > 0 {
+		for _, record := range batch.Records {
+			var request models.CodeProcessRequest
+			if err := json.Unmarshal([]byte(record.Body), &request); err != nil {
+				log.Printf("Skipping malformed queue message %s: %s\n", record.MessageId, err.Error())
+				continue
+			}
+
+			if err := r.HandleRequest(ctx, request); err != nil {
+				log.Printf("Error while handling queue message %s: %s\n", record.MessageId, err.Error())
+			}
+		}
+		return nil
 	}
-	b.WriteString("```")
-	b.WriteString(s)
-	b.WriteString("```")
-	return b.String()
-}
 
-func handleRequest(ctx context.Context, request models.CodeProcessRequest) error {
-	payload, err := json.Marshal(request)
-	if err != nil {
+	var request models.CodeProcessRequest
+	if err := json.Unmarshal(event, &request); err != nil {
 		return err
 	}
 
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-
-	client := lambdaClient.New(sess, &aws.Config{Region: aws.String(os.Getenv("AWS_REGION"))})
-
-	input := lambdaClient.InvokeInput{
-		FunctionName: aws.String(os.Getenv("CODE_EXEC_LAMBDA_ARN")),
-		Payload:      payload,
-	}
+	return r.HandleRequest(ctx, request)
+}
 
-	log.Printf("Invoking code exec lambda...\n")
-	output, err := client.Invoke(&input)
+func main() {
+	executor, err := invoker.New(invoker.Config{
+		Backend:      os.Getenv("CODE_EXEC_INVOKER"),
+		FunctionName: os.Getenv("CODE_EXEC_LAMBDA_ARN"),
+	})
 	if err != nil {
-		slack.SendChannelResponse(request.ResponseURL, "Sorry! Unable to setup execution environment :(")
-		log.Printf("Error while invoking code runner: %s\n", err.Error())
-		return err
+		panic(err)
 	}
 
-	var codeOutput CodeOutput
-	err = json.Unmarshal(output.Payload, &codeOutput)
-	if err != nil {
-		slack.SendChannelResponse(request.ResponseURL, "Sorry! Unable to setup execution environment :(")
-		log.Printf("Error while deserializing code output: %s\n", err.Error())
-		return err
+	r = responder.Responder{
+		Slack:    slack.NewClientFromEnv(),
+		Executor: executor,
 	}
 
-	fmt.Println(codeOutput.Output)
-
-	log.Printf("Sending slack response...\n")
-
-	if codeOutput.Output == "" {
-		codeOutput.Output = "[No output]"
+	if threshold, err := strconv.Atoi(os.Getenv("RESL_SNIPPET_THRESHOLD")); err == nil {
+		r.SnippetThreshold = threshold
 	}
 
-	escapedOutput := escapeString(string(codeOutput.Output))
-	slackResponse := wrapString(request, escapedOutput)
-
-	slack.SendChannelResponse(request.ResponseURL, slackResponse)
-
-	return nil
-}
+	if config := store.ConfigFromEnv(); config.Backend != "" {
+		db, err := store.New(config)
+		if err != nil {
+			panic(err)
+		}
+		r.Store = db
+		// workspaces have their own tokens once installed through OAuth
+		if os.Getenv("SLACK_CLIENT_ID") != "" {
+			r.Installations = db
+		}
+	}
 
-func main() {
-	lambda.Start(handleRequest)
+	lambda.Start(handleEvent)
 }
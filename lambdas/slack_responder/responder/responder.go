@@ -0,0 +1,251 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stripedpajamas/resl/invoker"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/store"
+)
+
+// DefaultSnippetThreshold is the result length, in characters, past which
+// the output is uploaded as a file snippet instead of posted in a message
+const DefaultSnippetThreshold = 3000
+
+// a snippet preview shows at most this many lines and characters of each section
+const previewLines = 10
+const previewChars = 500
+
+// Responder runs the code from a listener request and posts the result back
+// to slack
+type Responder struct {
+	// Slack calls slack, with each workspace's own token when there are
+	// Installations
+	Slack *slack.Client
+	// Installations, when set, holds the bot token of each workspace the app
+	// was installed in through OAuth. Other workspaces are turned away
+	Installations slack.InstallationStore
+	// Store, when set, keeps every run for the user's history
+	Store store.Store
+	// Executor runs a serialized models.CodeProcessRequest and returns the
+	// serialized models.CodeOutput
+	Executor invoker.Invoker
+	// SnippetThreshold overrides DefaultSnippetThreshold
+	SnippetThreshold int
+}
+
+// cuts text down to the first few lines for a preview
+func truncate(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > previewLines {
+		s = strings.TrimRight(strings.Join(lines[:previewLines], ""), "\n") + "\n…"
+	}
+
+	return slack.Clip(s, previewChars)
+}
+
+// previewOutput returns a copy of the output with each section truncated
+func previewOutput(output models.CodeOutput) models.CodeOutput {
+	preview := models.CodeOutput{}
+	if output.Compile != nil {
+		compile := *output.Compile
+		compile.Stdout = truncate(compile.Stdout)
+		compile.Stderr = truncate(compile.Stderr)
+		preview.Compile = &compile
+	}
+	if output.Run != nil {
+		run := *output.Run
+		run.Stdout = truncate(run.Stdout)
+		run.Stderr = truncate(run.Stderr)
+		preview.Run = &run
+	}
+	return preview
+}
+
+// fullOutput joins every section of the output into plain text for a snippet
+func fullOutput(output models.CodeOutput) string {
+	var b strings.Builder
+
+	if compile := output.Compile; compile != nil && compile.Stdout+compile.Stderr != "" {
+		if output.Run != nil {
+			b.WriteString("--- compiler output ---\n")
+		}
+		b.WriteString(compile.Stdout + compile.Stderr)
+		if output.Run != nil {
+			b.WriteString("\n--- program output ---\n")
+		}
+	}
+
+	if run := output.Run; run != nil {
+		b.WriteString(run.Stdout)
+		if run.Stderr != "" {
+			b.WriteString("\n--- stderr ---\n")
+			b.WriteString(run.Stderr)
+		}
+	}
+
+	if (output.Compile != nil && output.Compile.Truncated) || (output.Run != nil && output.Run.Truncated) {
+		b.WriteString("\n--- output truncated ---\n")
+	}
+
+	return b.String()
+}
+
+// runs the code with the executor and waits for its output
+func (r *Responder) execute(ctx context.Context, request models.CodeProcessRequest) (models.CodeOutput, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return models.CodeOutput{}, err
+	}
+
+	output, err := r.Executor.Invoke(ctx, payload)
+	if err != nil {
+		return models.CodeOutput{}, err
+	}
+
+	var codeOutput models.CodeOutput
+	if err = json.Unmarshal(output, &codeOutput); err != nil {
+		return models.CodeOutput{}, err
+	}
+
+	return codeOutput, nil
+}
+
+// HandleRequest runs the requested code and sends the formatted result to
+// the request's response url
+func (r *Responder) HandleRequest(ctx context.Context, request models.CodeProcessRequest) error {
+	log.Printf("Running code...\n")
+	codeOutput, err := r.execute(ctx, request)
+	if err != nil {
+		log.Printf("Error while running code: %s\n", err.Error())
+		r.sendError(ctx, request, "Sorry! Unable to setup execution environment :(")
+		return err
+	}
+
+	log.Printf("Sending slack response...\n")
+
+	err = r.sendResult(ctx, request, codeOutput)
+	r.record(ctx, request, codeOutput)
+	if err != nil {
+		var apiErr *slack.APIError
+		if errors.As(err, &apiErr) {
+			log.Printf("Slack rejected the result with status %d: %s\n", apiErr.StatusCode, apiErr.Code)
+			r.sendError(ctx, request, fmt.Sprintf("Sorry! Slack rejected the result (%s) :(", apiErr.Code))
+		} else {
+			log.Printf("Error while sending the result to slack: %s\n", err.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// saves the run to the user's history, only logging when that fails since
+// the result has been sent either way
+func (r *Responder) record(ctx context.Context, request models.CodeProcessRequest, output models.CodeOutput) {
+	if r.Store == nil || request.UserID == "" {
+		return
+	}
+
+	if err := store.Record(ctx, r.Store, store.NewRun(request, output)); err != nil {
+		log.Printf("Error while saving the run: %s\n", err.Error())
+	}
+}
+
+// posts the result, uploading the full output as a snippet and posting a
+// preview when it is too long for a message
+func (r *Responder) sendResult(ctx context.Context, request models.CodeProcessRequest, output models.CodeOutput) error {
+	threshold := r.SnippetThreshold
+	if threshold == 0 {
+		threshold = DefaultSnippetThreshold
+	}
+
+	message := slack.ResultMessage(request, output)
+	length := utf8.RuneCountInString(message.Text)
+	if length <= threshold {
+		return r.send(ctx, request, message)
+	}
+
+	if request.ChannelID == "" {
+		log.Printf("Result is too long but there is no channel to upload it to\n")
+		return r.send(ctx, request, previewMessage(request, output, "_Output truncated_"))
+	}
+
+	log.Printf("Result is %d characters, uploading it as a snippet\n", length)
+	var file slack.File
+	client, err := r.slackFor(ctx, request)
+	if err == nil {
+		file, err = client.UploadFile(ctx, slack.FileUpload{
+			Filename:    "output.txt",
+			Title:       request.Props.Name + " output",
+			Content:     []byte(fullOutput(output)),
+			SnippetType: request.Props.SnippetType,
+			ChannelID:   request.ChannelID,
+			ThreadTS:    request.ThreadTS,
+		})
+	}
+	if err != nil {
+		log.Printf("Error while uploading output snippet: %s\n", err.Error())
+		return r.send(ctx, request, previewMessage(request, output, "_Output truncated_"))
+	}
+
+	return r.send(ctx, request, previewMessage(request, output, "_Output truncated, see <"+file.Permalink+"|the full output>_"))
+}
+
+// renders a truncated copy of the result followed by a note about the rest
+func previewMessage(request models.CodeProcessRequest, output models.CodeOutput, note string) slack.Response {
+	message := slack.ResultMessage(request, previewOutput(output))
+	message.Text = strings.TrimRight(message.Text, "\n") + "\n" + note
+
+	// keep the note with the output, above any buttons
+	blocks := message.Blocks
+	at := len(blocks)
+	if at > 0 && blocks[at-1].BlockID == slack.ResultActionsBlockName {
+		at--
+	}
+	message.Blocks = append(blocks[:at:at], slack.ContextBlock(note))
+	message.Blocks = append(message.Blocks, blocks[at:]...)
+
+	return message
+}
+
+// returns the client that calls slack on behalf of the request's workspace
+func (r *Responder) slackFor(ctx context.Context, request models.CodeProcessRequest) (*slack.Client, error) {
+	return slack.ClientForTeam(ctx, r.Slack, r.Installations, request.TeamID, request.EnterpriseID)
+}
+
+// posts a message to the request's thread if it has one, otherwise to its
+// response url, which can only post at the top of the channel
+func (r *Responder) send(ctx context.Context, request models.CodeProcessRequest, message slack.Response) error {
+	if request.ThreadTS != "" && request.ChannelID != "" {
+		client, err := r.slackFor(ctx, request)
+		if err == nil {
+			_, err = client.PostMessage(ctx, slack.ChatMessage{
+				Channel:  request.ChannelID,
+				Text:     message.Text,
+				Blocks:   message.Blocks,
+				ThreadTS: request.ThreadTS,
+			})
+		}
+		if err == nil || request.ResponseURL == "" {
+			return err
+		}
+		log.Printf("Error while posting to the thread, using the response url instead: %s\n", err.Error())
+	}
+
+	return r.Slack.SendChannelMessage(ctx, request.ResponseURL, message)
+}
+
+// sends a short error message in place of the result, logging if even that fails
+func (r *Responder) sendError(ctx context.Context, request models.CodeProcessRequest, text string) {
+	if err := r.send(ctx, request, slack.Response{Text: text}); err != nil {
+		log.Printf("Error while reporting failure to slack: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,291 @@
+package responder_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stripedpajamas/resl/invoker/invokertest"
+	"github.com/stripedpajamas/resl/lambdas/slack_responder/responder"
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+	"github.com/stripedpajamas/resl/slack/slacktest"
+	"github.com/stripedpajamas/resl/store"
+)
+
+var python = models.LanguageProperties{Name: "Python", ShortName: "py3", Version: "3.7", Extension: "py", SnippetType: "python"}
+
+// newResponder returns a responder wired to a fake slack and a fake code
+// runner that answers with output
+func newResponder(t *testing.T, output models.CodeOutput) (*responder.Responder, *slacktest.Server, *invokertest.Fake) {
+	t.Helper()
+
+	server := slacktest.NewServer("responder-test-secret")
+	server.Token = "xoxb-test"
+	t.Cleanup(server.Close)
+
+	payload, err := json.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := &invokertest.Fake{Response: payload}
+
+	return &responder.Responder{
+		Slack:    server.Client(),
+		Executor: executor,
+	}, server, executor
+}
+
+func request(server *slacktest.Server) models.CodeProcessRequest {
+	return models.CodeProcessRequest{
+		ResponseURL: server.ResponseURL("default"),
+		Code:        `print("hello")`,
+		Props:       python,
+		UserID:      "U1",
+		ChannelID:   "C1",
+		TeamID:      "T1",
+	}
+}
+
+func succeeded(stdout string) models.CodeOutput {
+	return models.CodeOutput{Run: &models.ExecutionResult{Stdout: stdout, DurationMs: 12}}
+}
+
+// finds the first section block whose text contains s
+func findSection(blocks []slack.Block, s string) *slack.Block {
+	for i, block := range blocks {
+		if block.Type == "section" && block.Text != nil && strings.Contains(block.Text.Text, s) {
+			return &blocks[i]
+		}
+	}
+	return nil
+}
+
+func TestResultIsPostedToResponseURL(t *testing.T) {
+	r, server, executor := newResponder(t, succeeded("hello\n"))
+
+	if err := r.HandleRequest(context.Background(), request(server)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	calls := executor.Calls()
+	if len(calls) != 1 || calls[0].Async {
+		t.Fatalf("expected one synchronous run, got %+v", calls)
+	}
+	var sent models.CodeProcessRequest
+	if err := json.Unmarshal(calls[0].Payload, &sent); err != nil || sent.Code != `print("hello")` {
+		t.Errorf("expected the request to be passed to the runner, got %s", calls[0].Payload)
+	}
+
+	posts := server.Posts("default")
+	if len(posts) != 1 {
+		t.Fatalf("expected one post to the response url, got %d", len(posts))
+	}
+	response := posts[0].Response
+	if response.ResponseType != "in_channel" {
+		t.Errorf("expected the result to be shown in channel, got %q", response.ResponseType)
+	}
+	if !strings.Contains(response.Text, "hello") {
+		t.Errorf("expected the output in the fallback text, got %q", response.Text)
+	}
+	if len(response.Blocks) == 0 || response.Blocks[0].Type != "header" || response.Blocks[0].Text.Text != "Python (3.7)" {
+		t.Errorf("expected a header with the language, got %+v", response.Blocks)
+	}
+	if findSection(response.Blocks, "*Output*\n```hello\n```") == nil {
+		t.Errorf("expected an output section, got %+v", response.Blocks)
+	}
+	if last := response.Blocks[len(response.Blocks)-1]; last.BlockID != slack.ResultActionsBlockName {
+		t.Errorf("expected re-run buttons last, got %+v", last)
+	}
+
+	if len(server.Messages()) != 0 {
+		t.Errorf("expected nothing posted with chat.postMessage")
+	}
+}
+
+func TestThreadResultIsPostedAsReply(t *testing.T) {
+	r, server, _ := newResponder(t, succeeded("hello\n"))
+
+	req := request(server)
+	req.ThreadTS = "1600000000.000100"
+	if err := r.HandleRequest(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	calls := server.Calls("chat.postMessage")
+	if len(calls) != 1 {
+		t.Fatalf("expected one chat.postMessage call, got %d", len(calls))
+	}
+	if auth := calls[0].Header.Get("Authorization"); auth != "Bearer xoxb-test" {
+		t.Errorf("expected the bot token, got %q", auth)
+	}
+
+	message := server.Messages()[0]
+	if message.Channel != "C1" || message.ThreadTS != "1600000000.000100" {
+		t.Errorf("expected a reply in the thread, got channel %q thread %q", message.Channel, message.ThreadTS)
+	}
+	if findSection(message.Blocks, "hello") == nil || !strings.Contains(message.Text, "hello") {
+		t.Errorf("expected the result in the reply, got %+v", message)
+	}
+
+	if len(server.Posts("default")) != 0 {
+		t.Errorf("expected nothing posted to the response url")
+	}
+}
+
+func TestThreadReplyFallsBackToResponseURL(t *testing.T) {
+	r, server, _ := newResponder(t, succeeded("hello\n"))
+	server.Handle("chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":false,"error":"not_in_channel"}`))
+	})
+
+	req := request(server)
+	req.ThreadTS = "1600000000.000100"
+	if err := r.HandleRequest(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(server.Calls("chat.postMessage")) != 1 {
+		t.Errorf("expected the thread reply to be tried once")
+	}
+	if posts := server.Posts("default"); len(posts) != 1 || !strings.Contains(posts[0].Response.Text, "hello") {
+		t.Errorf("expected the result at the response url, got %+v", posts)
+	}
+}
+
+func TestLongOutputIsUploaded(t *testing.T) {
+	long := strings.Repeat("line of output\n", 400)
+	r, server, _ := newResponder(t, succeeded(long))
+
+	if err := r.HandleRequest(context.Background(), request(server)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	uploads := server.Uploads()
+	if len(uploads) != 1 || string(uploads[0].Content) != long {
+		t.Fatalf("expected the full output uploaded, got %d uploads", len(uploads))
+	}
+
+	var complete struct {
+		Files     []struct{ ID string } `json:"files"`
+		ChannelID string                `json:"channel_id"`
+	}
+	calls := server.Calls("files.completeUploadExternal")
+	if len(calls) != 1 {
+		t.Fatalf("expected the upload to be completed once, got %d", len(calls))
+	}
+	if err := json.Unmarshal(calls[0].Body, &complete); err != nil || complete.ChannelID != "C1" || len(complete.Files) != 1 || complete.Files[0].ID != uploads[0].FileID {
+		t.Errorf("expected the file shared in the channel, got %s", calls[0].Body)
+	}
+
+	posts := server.Posts("default")
+	if len(posts) != 1 {
+		t.Fatalf("expected one preview post, got %d", len(posts))
+	}
+	response := posts[0].Response
+	if len(response.Text) > responder.DefaultSnippetThreshold {
+		t.Errorf("expected a short preview, got %d characters", len(response.Text))
+	}
+	if !strings.Contains(response.Text, server.URL+"/files/"+uploads[0].FileID) {
+		t.Errorf("expected a link to the full output, got %q", response.Text)
+	}
+}
+
+func TestSnippetThresholdCountsCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		uploaded bool
+	}{
+		// twice as many bytes as characters, so past the threshold in bytes
+		{name: "under the threshold", output: strings.Repeat("é", 900)},
+		{name: "over the threshold", output: strings.Repeat("é", 1100), uploaded: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, server, _ := newResponder(t, succeeded(test.output))
+			r.SnippetThreshold = 1000
+
+			if err := r.HandleRequest(context.Background(), request(server)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if uploaded := len(server.Uploads()) > 0; uploaded != test.uploaded {
+				t.Errorf("expected uploaded %v, got %v", test.uploaded, uploaded)
+			}
+			posts := server.Posts("default")
+			if len(posts) != 1 {
+				t.Fatalf("expected one post, got %d", len(posts))
+			}
+			if full := strings.Contains(posts[0].Response.Text, test.output); full == test.uploaded {
+				t.Errorf("expected the full output in the message only when it isn't uploaded, got %q", posts[0].Response.Text)
+			}
+		})
+	}
+}
+
+func TestRunnerFailureIsReported(t *testing.T) {
+	r, server, executor := newResponder(t, models.CodeOutput{})
+	executor.Err = errors.New("lambda is down")
+
+	if err := r.HandleRequest(context.Background(), request(server)); err == nil {
+		t.Fatal("expected the runner's error")
+	}
+
+	posts := server.Posts("default")
+	if len(posts) != 1 || posts[0].Response.Text != "Sorry! Unable to setup execution environment :(" {
+		t.Errorf("expected an apology at the response url, got %+v", posts)
+	}
+}
+
+func TestSlackRejectionIsReported(t *testing.T) {
+	r, server, _ := newResponder(t, succeeded("hello\n"))
+	rejected := false
+	server.Handle("chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !rejected {
+			rejected = true
+			w.Write([]byte(`{"ok":false,"error":"invalid_blocks"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"ts":"1600000000.000200"}`))
+	})
+
+	req := request(server)
+	req.ThreadTS = "1600000000.000100"
+	req.ResponseURL = ""
+	if err := r.HandleRequest(context.Background(), req); err == nil {
+		t.Fatal("expected slack's error")
+	}
+
+	messages := server.Messages()
+	if len(messages) != 2 || messages[1].Text != "Sorry! Slack rejected the result (invalid_blocks) :(" {
+		t.Errorf("expected the rejection to be explained in the thread, got %+v", messages)
+	}
+}
+
+func TestRunIsRecorded(t *testing.T) {
+	r, server, _ := newResponder(t, succeeded("hello\n"))
+	db, err := store.OpenFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Store = db
+
+	if err := r.HandleRequest(context.Background(), request(server)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	runs, err := db.RecentRuns(context.Background(), "T1", "U1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Code != `print("hello")` || runs[0].Output.Run.Stdout != "hello\n" {
+		t.Errorf("expected the run in the user's history, got %+v", runs)
+	}
+}
@@ -2,32 +2,119 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 )
 
 // LanguageProperties represents properties for running each supported language
 type LanguageProperties struct {
 	Name           string `json:"langName"`
 	ShortName      string `json:"shortName"`
+	Version        string `json:"version"`
 	Extension      string `json:"extension"`
 	Placeholder    string `json:"placeholder"`
 	FileName       string `json:"fileName"`
 	RunCommand     string `json:"runCmd"`
 	CompileCommand string `json:"compileCmd"`
+	// SnippetType is the slack syntax highlighting used when output is
+	// posted as a file snippet, e.g. "python"
+	SnippetType string `json:"snippetType,omitempty"`
+	// Aliases are other names the language can be asked for by, e.g. "python"
+	Aliases []string `json:"aliases,omitempty"`
+	// CompileTimeout and RunTimeout are the time budgets in seconds for each
+	// phase of execution; zero means the runner's default
+	CompileTimeout int `json:"compileTimeout,omitempty"`
+	RunTimeout     int `json:"runTimeout,omitempty"`
 }
 
 // LanguageConfig represents the model matching the languages.json file
 type LanguageConfig map[string]LanguageProperties
 
+// Lookup returns the language with the short name or, ignoring case, with
+// the short name or alias
+func (c LanguageConfig) Lookup(name string) (LanguageProperties, bool) {
+	if props, found := c[name]; found {
+		return props, true
+	}
+
+	name = strings.ToLower(name)
+	for _, props := range c {
+		if strings.ToLower(props.ShortName) == name {
+			return props, true
+		}
+		for _, alias := range props.Aliases {
+			if strings.ToLower(alias) == name {
+				return props, true
+			}
+		}
+	}
+
+	return LanguageProperties{}, false
+}
+
+// checkAliases makes sure every short name and alias means a single language
+func (c LanguageConfig) checkAliases() error {
+	names := make(map[string]string)
+	for shortName, props := range c {
+		for _, name := range append([]string{shortName}, props.Aliases...) {
+			name = strings.ToLower(name)
+			if other, taken := names[name]; taken && other != shortName {
+				return fmt.Errorf("%q is a name of both %s and %s", name, other, shortName)
+			}
+			names[name] = shortName
+		}
+	}
+	return nil
+}
+
 // CodeProcessRequest represents the payload sent to the code runner lambda
 type CodeProcessRequest struct {
 	ResponseURL string             `json:"responseUrl,omitempty"`
 	Code        string             `json:"code,omitempty"`
 	Props       LanguageProperties `json:"props,omitempty"`
 	UserID      string             `json:"userId,omitempty"`
-	Modal       bool               `json:"modal,omitempty"`
+	ChannelID   string             `json:"channelId,omitempty"`
+	// TeamID and EnterpriseID pick the bot token for the workspace
+	TeamID       string `json:"teamId,omitempty"`
+	EnterpriseID string `json:"enterpriseId,omitempty"`
+	// ThreadTS is the thread the result belongs in, if any
+	ThreadTS string `json:"threadTs,omitempty"`
+	Modal    bool   `json:"modal,omitempty"`
+	Stdin    string `json:"stdin,omitempty"`
+}
+
+// ExecutionResult represents the outcome of one phase (compile or run) of
+// executing submitted code
+type ExecutionResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exitCode"`
+	Signal     string `json:"signal,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	// Truncated is set when stdout or stderr was longer than the runner keeps
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Succeeded reports whether the process exited on its own with status 0
+func (r ExecutionResult) Succeeded() bool {
+	return r.ExitCode == 0 && r.Signal == "" && !r.TimedOut
+}
+
+// CodeOutput represents the result returned by the code runner lambda. Compile
+// is only set for languages with a compile command, and Run is nil when
+// compilation failed
+type CodeOutput struct {
+	Compile *ExecutionResult `json:"compile,omitempty"`
+	Run     *ExecutionResult `json:"run,omitempty"`
+}
+
+// CompileFailed reports whether there was a compile step and it did not succeed
+func (o CodeOutput) CompileFailed() bool {
+	return o.Compile != nil && !o.Compile.Succeeded()
 }
 
 // ImportLanguageConfig reads and parses the languages configuration json file
@@ -48,5 +135,9 @@ func ImportLanguageConfig(filePath string) (LanguageConfig, error) {
 		return nil, err
 	}
 
+	if err = config.checkAliases(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
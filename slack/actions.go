@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// BlockActionsType is the interaction payload type sent for button presses
+const BlockActionsType = "block_actions"
+
+// MessageActionType is the interaction payload type sent for message shortcuts
+const MessageActionType = "message_action"
+
+// RunShortcutCallbackID represents the "Run this code" message shortcut
+const RunShortcutCallbackID = "run_code"
+
+// ResultActionsBlockName represents the name of the result message buttons block
+const ResultActionsBlockName = "result_actions"
+
+// RerunActionID represents the action of the result message re-run button
+const RerunActionID = "rerun"
+
+// EditRerunActionID represents the action of the result message edit & re-run button
+const EditRerunActionID = "edit_rerun"
+
+// maxButtonValue is the most characters slack allows in a button's value
+const maxButtonValue = 2000
+
+// RunAction is carried in the value of the result message buttons so the run
+// can be repeated without looking it up again. Buttons on saved runs only
+// carry the RunID
+type RunAction struct {
+	Language string `json:"l,omitempty"`
+	Code     string `json:"c,omitempty"`
+	Stdin    string `json:"i,omitempty"`
+	RunID    string `json:"r,omitempty"`
+}
+
+// ParseRunAction reads the run carried in a result message button's value
+func ParseRunAction(value string) (RunAction, error) {
+	var action RunAction
+	err := json.Unmarshal([]byte(value), &action)
+	return action, err
+}
+
+func button(actionID, text, value string) Element {
+	return Element{
+		Type:     "button",
+		ActionID: actionID,
+		Text: &ViewOptions{
+			Type:  plainTextType,
+			Text:  text,
+			Emoji: true,
+		},
+		Value: value,
+	}
+}
+
+// resultActions returns the re-run buttons for a result message, or nothing
+// when the run is too big to fit in a button
+func resultActions(request models.CodeProcessRequest) []Block {
+	if request.Props.ShortName == "" {
+		return nil
+	}
+
+	value, err := json.Marshal(RunAction{
+		Language: request.Props.ShortName,
+		Code:     request.Code,
+		Stdin:    request.Stdin,
+	})
+	if err != nil || len(value) > maxButtonValue {
+		log.Printf("Run is too big for the re-run buttons, leaving them out\n")
+		return nil
+	}
+
+	return []Block{
+		Block{
+			BlockID: ResultActionsBlockName,
+			Type:    "actions",
+			Elements: []interface{}{
+				button(RerunActionID, "Re-run", string(value)),
+				button(EditRerunActionID, "Edit & re-run", string(value)),
+			},
+		},
+	}
+}
+
+// Prefill returns the modal content to edit the action's run with
+func (a RunAction) Prefill() ModalPrefill {
+	return ModalPrefill{
+		Code:     a.Code,
+		Language: a.Language,
+		Stdin:    a.Stdin,
+	}
+}
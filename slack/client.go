@@ -0,0 +1,366 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// DefaultBaseURL is the base URL of the slack web API
+const DefaultBaseURL = "https://slack.com/api/"
+
+// DefaultTimeout bounds each request made by a Client without its own HTTPClient
+const DefaultTimeout = 10 * time.Second
+
+const jsonContentType = "application/json"
+const formContentType = "application/x-www-form-urlencoded"
+
+// DefaultMaxRetries is how many times a Client retries a transient failure
+const DefaultMaxRetries = 3
+
+// DefaultBackoff is the base delay between retries, doubled on every attempt
+const DefaultBackoff = 500 * time.Millisecond
+
+// maxBackoff caps the delay between retries
+const maxBackoff = 30 * time.Second
+
+// nonIdempotent lists the calls that must not be repeated once slack may have
+// seen them, since they would post the message or share the file twice
+var nonIdempotent = map[string]bool{
+	"chat.postMessage":             true,
+	"files.completeUploadExternal": true,
+	responseURLMethod:              true,
+}
+
+// neverRetried lists the calls that are useless to repeat, since their
+// trigger_id expires three seconds after the user acted
+var neverRetried = map[string]bool{
+	"views.open": true,
+}
+
+// Client talks to the slack web API and to response urls. The zero value
+// talks to slack.com without a token
+type Client struct {
+	// BaseURL is the web API base URL, e.g. an Enterprise Grid endpoint, a
+	// proxy or a fake; defaults to DefaultBaseURL
+	BaseURL string
+	// Token is the bot token sent with web API calls
+	Token string
+	// HTTPClient is used for every request; defaults to a client with Timeout
+	HTTPClient *http.Client
+	// Timeout bounds each request when HTTPClient is not set; defaults to DefaultTimeout
+	Timeout time.Duration
+	// MaxRetries is how many times transient failures (rate limits, 5xx
+	// responses, network errors) are retried; defaults to DefaultMaxRetries,
+	// negative disables retries. Calls that post a message or share a file
+	// are only retried when slack cannot have acted on them
+	MaxRetries int
+	// Backoff is the base delay between retries; defaults to DefaultBackoff
+	Backoff time.Duration
+	// Logger defaults to the standard logger
+	Logger *log.Logger
+}
+
+// NewClient returns a client for slack.com using the bot token
+func NewClient(token string) *Client {
+	return &Client{Token: token}
+}
+
+// NewClientFromEnv returns a client configured by the SLACK_TOKEN and, when
+// set, SLACK_API_URL environment variables
+func NewClientFromEnv() *Client {
+	return &Client{
+		BaseURL: os.Getenv("SLACK_API_URL"),
+		Token:   os.Getenv("SLACK_TOKEN"),
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func (c *Client) methodURL(method string) string {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return baseURL + method
+}
+
+// call posts a body to a web API method, response url or upload url,
+// retrying transient failures, and returns the response body
+func (c *Client) call(ctx context.Context, method, url, contentType string, reqBody []byte, authorize bool) ([]byte, error) {
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, err := c.post(ctx, method, url, contentType, reqBody, authorize)
+		if err == nil || attempt >= maxRetries || !retryable(ctx, method, err) {
+			return body, err
+		}
+
+		wait := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+
+		// waiting past the deadline would only fail later with a vaguer error
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			c.logf("Slack call to %s failed (%s), not retrying since the next try is due after the deadline\n", method, err.Error())
+			return body, err
+		}
+
+		c.logf("Slack call to %s failed (%s), retrying in %s\n", method, err.Error(), wait)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable reports whether a failed call may succeed if tried again without
+// repeating anything slack has already done
+func retryable(ctx context.Context, method string, err error) bool {
+	if ctx.Err() != nil || neverRetried[method] {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		// a rate limited call was turned away before slack acted on it
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+		return apiErr.Temporary() && !nonIdempotent[method]
+	}
+
+	// anything else is a transport failure, which may have happened after
+	// slack received the request
+	return !nonIdempotent[method] || neverSent(err)
+}
+
+// neverSent reports whether a transport error happened before the request
+// could reach slack, e.g. a failed DNS lookup or a refused connection
+func neverSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// backoff returns a random delay up to the base backoff doubled per attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.Backoff
+	if base == 0 {
+		base = DefaultBackoff
+	}
+
+	limit := base << uint(attempt)
+	if limit <= 0 || limit > maxBackoff {
+		limit = maxBackoff
+	}
+
+	return base/2 + time.Duration(rand.Int63n(int64(limit)))
+}
+
+// post sends a body once and returns the response body, or an APIError if
+// slack did not accept it
+func (c *Client) post(ctx context.Context, method, url, contentType string, reqBody []byte, authorize bool) ([]byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Add("Content-Type", contentType)
+	if authorize {
+		req.Header.Add("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkResponse(method, resp, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// callJSON calls a web API method with a json body and decodes the response into out
+func (c *Client) callJSON(ctx context.Context, method string, args interface{}, out interface{}) error {
+	reqBody, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	body, err := c.call(ctx, method, c.methodURL(method), jsonContentType, reqBody, true)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// callForm calls a web API method with form encoded arguments, which some
+// methods require, and decodes the response into out
+func (c *Client) callForm(ctx context.Context, method string, args url.Values, out interface{}) error {
+	body, err := c.call(ctx, method, c.methodURL(method), formContentType, []byte(args.Encode()), true)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// SendChannelResponse sends text to a response url in a channel
+func (c *Client) SendChannelResponse(ctx context.Context, url, text string) error {
+	return c.SendChannelMessage(ctx, url, Response{Text: text})
+}
+
+// ChatMessage is a message posted with chat.postMessage
+type ChatMessage struct {
+	Channel string  `json:"channel"`
+	Text    string  `json:"text,omitempty"`
+	Blocks  []Block `json:"blocks,omitempty"`
+	// ThreadTS posts the message as a reply in a thread
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+type postMessageResponse struct {
+	TS string `json:"ts"`
+}
+
+// PostMessage posts a message to a channel the app is in, returning its
+// timestamp
+func (c *Client) PostMessage(ctx context.Context, message ChatMessage) (string, error) {
+	var posted postMessageResponse
+	if err := c.callJSON(ctx, "chat.postMessage", message, &posted); err != nil {
+		return "", err
+	}
+
+	return posted.TS, nil
+}
+
+type connectionResponse struct {
+	URL string `json:"url"`
+}
+
+// OpenConnection returns the url of a new socket mode websocket. The client
+// must use an app-level token (xapp-...) rather than a bot token
+func (c *Client) OpenConnection(ctx context.Context) (string, error) {
+	var connection connectionResponse
+	if err := c.callJSON(ctx, "apps.connections.open", struct{}{}, &connection); err != nil {
+		return "", err
+	}
+	if connection.URL == "" {
+		return "", errors.New("slack returned no socket mode url")
+	}
+
+	return connection.URL, nil
+}
+
+// SendPrivateResponse sends text to a response url visible only to the user
+// who triggered it
+func (c *Client) SendPrivateResponse(ctx context.Context, url, text string) error {
+	return c.respond(ctx, url, Response{Text: text})
+}
+
+// SendChannelMessage posts a message, e.g. one built by ResultMessage, to the
+// channel behind a response url
+func (c *Client) SendChannelMessage(ctx context.Context, url string, message Response) error {
+	message.ResponseType = "in_channel"
+	return c.respond(ctx, url, message)
+}
+
+func (c *Client) respond(ctx context.Context, url string, message Response) error {
+	reqBody, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.call(ctx, responseURLMethod, url, jsonContentType, reqBody, false)
+
+	return err
+}
+
+// SendModal sends a modal to the user who typed the command. The modal
+// has language-specific placeholder code and shows the chosen language name,
+// or lets the user pick from the configured languages if none was chosen
+func (c *Client) SendModal(ctx context.Context, triggerID string, languages models.LanguageConfig, languageShortName string) error {
+	return c.OpenModal(ctx, triggerID, GenerateRESLModal(languages, languageShortName, ModalPrefill{}))
+}
+
+// OpenModal opens a modal, e.g. one built by GenerateRESLModal, in response to a trigger
+func (c *Client) OpenModal(ctx context.Context, triggerID string, view ModalDefinition) error {
+	reqBody, err := json.Marshal(ModalRequest{
+		TriggerID: triggerID,
+		View:      view,
+	})
+	if err != nil {
+		return err
+	}
+	c.logf("Modal request body: %s\n", string(reqBody))
+
+	body, err := c.call(ctx, "views.open", c.methodURL("views.open"), jsonContentType, reqBody, true)
+	if err != nil {
+		return err
+	}
+
+	c.logf("Slack response: %s\n", string(body))
+
+	return nil
+}
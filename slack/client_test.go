@@ -0,0 +1,150 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "https://slack.com/api/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	dnsErr := &url.Error{Op: "Post", URL: "https://slack.com/api/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "slack.com"}}}
+	readErr := &url.Error{Op: "Post", URL: "https://slack.com/api/", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	eofErr := &url.Error{Op: "Post", URL: "https://slack.com/api/", Err: io.EOF}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		err    error
+		want   bool
+	}{
+		{"rate limited", context.Background(), "files.info", &APIError{StatusCode: 429}, true},
+		{"rate limited post", context.Background(), "chat.postMessage", &APIError{StatusCode: 429}, true},
+		{"server error", context.Background(), "files.info", &APIError{StatusCode: 503}, true},
+		{"server error on post", context.Background(), "chat.postMessage", &APIError{StatusCode: 503}, false},
+		{"server error on response url", context.Background(), responseURLMethod, &APIError{StatusCode: 500}, false},
+		{"client error", context.Background(), "files.info", &APIError{StatusCode: 400, Code: "invalid_auth"}, false},
+		{"refused connection", context.Background(), "files.info", dialErr, true},
+		{"refused connection on post", context.Background(), "chat.postMessage", dialErr, true},
+		{"unknown host on upload", context.Background(), "files.completeUploadExternal", dnsErr, true},
+		{"reset connection", context.Background(), "files.info", readErr, true},
+		{"reset connection on post", context.Background(), "chat.postMessage", readErr, false},
+		{"dropped response on upload", context.Background(), "files.completeUploadExternal", eofErr, false},
+		{"canceled", canceled, "files.info", dialErr, false},
+		{"rate limited modal", context.Background(), "views.open", &APIError{StatusCode: 429}, false},
+		{"server error on modal", context.Background(), "views.open", &APIError{StatusCode: 503}, false},
+		{"refused connection on modal", context.Background(), "views.open", dialErr, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryable(test.ctx, test.method, test.err); got != test.want {
+				t.Errorf("retryable(%s, %v) = %v; want %v", test.method, test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// serves every call with the given status until it has failed fails times
+func failing(status, fails int, header http.Header) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(atomic.AddInt32(&calls, 1)) <= fails {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"ok":true,"ts":"1600000000.000100"}`))
+	}))
+	return server, &calls
+}
+
+func TestCallRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+		calls  int32
+		failed bool
+	}{
+		{name: "idempotent call after a server error", method: "files.info", status: 503, calls: 2},
+		{name: "post after a server error", method: "chat.postMessage", status: 503, calls: 1, failed: true},
+		{name: "post after a rate limit", method: "chat.postMessage", status: 429, calls: 2},
+		{name: "bad request", method: "files.info", status: 400, calls: 1, failed: true},
+		{name: "modal after a server error", method: "views.open", status: 503, calls: 1, failed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := failing(test.status, 1, nil)
+			defer server.Close()
+
+			c := &Client{BaseURL: server.URL, Backoff: time.Millisecond}
+			err := c.callJSON(context.Background(), test.method, struct{}{}, nil)
+			if failed := err != nil; failed != test.failed {
+				t.Errorf("expected failure %v, got %v", test.failed, err)
+			}
+			if got := atomic.LoadInt32(calls); got != test.calls {
+				t.Errorf("expected %d calls, got %d", test.calls, got)
+			}
+		})
+	}
+}
+
+func TestCallRetriesRefusedPost(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var logs bytes.Buffer
+	c := &Client{BaseURL: "http://" + addr, Backoff: time.Millisecond, Logger: log.New(&logs, "", 0)}
+	if _, err := c.PostMessage(context.Background(), ChatMessage{Channel: "C1", Text: "hi"}); err == nil {
+		t.Fatal("expected the refused connection to fail")
+	}
+	if retries := strings.Count(logs.String(), "retrying in"); retries != DefaultMaxRetries {
+		t.Errorf("expected %d retries of a post that never reached slack, got %d", DefaultMaxRetries, retries)
+	}
+}
+
+func TestCallRetryAfterPastDeadline(t *testing.T) {
+	server, calls := failing(429, 1, http.Header{"Retry-After": {"5"}})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	c := &Client{BaseURL: server.URL, Logger: log.New(ioutil.Discard, "", 0)}
+	err := c.callJSON(ctx, "files.info", struct{}{}, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 5*time.Second {
+		t.Errorf("expected the rate limit error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected to give up without waiting, took %s", elapsed)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("expected 1 call, got %d", got)
+	}
+}
@@ -0,0 +1,67 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// responseURLMethod names response url posts in an APIError
+const responseURLMethod = "response_url"
+
+// APIError is returned when slack answers a call with an error status or an
+// {"ok": false} envelope
+type APIError struct {
+	// Method is the web API method called, or "response_url"
+	Method     string
+	StatusCode int
+	// Code is slack's error code, e.g. "invalid_auth" or "ratelimited", or
+	// the HTTP status text when slack gave none
+	Code string
+	// RetryAfter is how long slack asked us to wait before trying again
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("slack %s failed with status %d: %s", e.Method, e.StatusCode, e.Code)
+}
+
+// Temporary reports whether the call may succeed if retried
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// envelope is the part of every web API response that reports success
+type envelope struct {
+	OK    *bool  `json:"ok"`
+	Error string `json:"error"`
+}
+
+// checkResponse turns error statuses and {"ok": false} envelopes into an
+// APIError. Response urls answer with plain text, so bodies that are not
+// json are accepted as long as the status is
+func checkResponse(method string, resp *http.Response, body []byte) error {
+	var env envelope
+	json.Unmarshal(body, &env)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && (env.OK == nil || *env.OK) {
+		return nil
+	}
+
+	apiErr := &APIError{
+		Method:     method,
+		StatusCode: resp.StatusCode,
+		Code:       env.Error,
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = http.StatusText(resp.StatusCode)
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return apiErr
+}
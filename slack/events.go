@@ -0,0 +1,32 @@
+package slack
+
+// URLVerificationType is the Events API request slack sends to check the
+// request url, which must be answered with its challenge
+const URLVerificationType = "url_verification"
+
+// EventCallbackType is the Events API request that carries an event
+const EventCallbackType = "event_callback"
+
+// AppMentionEvent is the event type sent when a user @-mentions the app
+const AppMentionEvent = "app_mention"
+
+// Event represents an event delivered through the Events API
+type Event struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// EventRequest represents the body of an Events API request
+type EventRequest struct {
+	Type         string `json:"type"`
+	Challenge    string `json:"challenge"`
+	TeamID       string `json:"team_id"`
+	EnterpriseID string `json:"enterprise_id"`
+	EventID      string `json:"event_id"`
+	Event        Event  `json:"event"`
+}
@@ -0,0 +1,93 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// FileUpload describes a file to share in a channel
+type FileUpload struct {
+	Filename string
+	Title    string
+	Content  []byte
+	// SnippetType is the syntax highlighting for the snippet, e.g. "python"
+	SnippetType string
+	ChannelID   string
+	// ThreadTS shares the file in a thread rather than the channel
+	ThreadTS string
+}
+
+// File represents an uploaded slack file
+type File struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Permalink string `json:"permalink"`
+}
+
+type uploadURLResponse struct {
+	UploadURL string `json:"upload_url"`
+	FileID    string `json:"file_id"`
+}
+
+type completedFile struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+type completeUploadRequest struct {
+	Files     []completedFile `json:"files"`
+	ChannelID string          `json:"channel_id,omitempty"`
+	ThreadTS  string          `json:"thread_ts,omitempty"`
+}
+
+type fileInfoResponse struct {
+	File File `json:"file"`
+}
+
+// UploadFile uploads content through slack's external upload flow and shares
+// it in the channel, returning the file with its permalink
+func (c *Client) UploadFile(ctx context.Context, upload FileUpload) (File, error) {
+	args := url.Values{
+		"filename": {upload.Filename},
+		"length":   {strconv.Itoa(len(upload.Content))},
+	}
+	if upload.SnippetType != "" {
+		args.Set("snippet_type", upload.SnippetType)
+	}
+
+	var uploadURL uploadURLResponse
+	if err := c.callForm(ctx, "files.getUploadURLExternal", args, &uploadURL); err != nil {
+		return File{}, err
+	}
+	if uploadURL.UploadURL == "" {
+		return File{}, errors.New("slack returned no upload url")
+	}
+
+	if _, err := c.call(ctx, "upload", uploadURL.UploadURL, "application/octet-stream", upload.Content, false); err != nil {
+		return File{}, err
+	}
+
+	title := upload.Title
+	if title == "" {
+		title = upload.Filename
+	}
+
+	err := c.callJSON(ctx, "files.completeUploadExternal", completeUploadRequest{
+		Files:     []completedFile{{ID: uploadURL.FileID, Title: title}},
+		ChannelID: upload.ChannelID,
+		ThreadTS:  upload.ThreadTS,
+	}, nil)
+	if err != nil {
+		return File{}, err
+	}
+
+	// completing the upload doesn't say where the file lives
+	var info fileInfoResponse
+	if err = c.callForm(ctx, "files.info", url.Values{"file": {uploadURL.FileID}}, &info); err != nil {
+		return File{}, err
+	}
+
+	return info.File, nil
+}
@@ -1,3 +1,10 @@
 module github.com/stripedpajamas/resl/slack
 
 go 1.15
+
+require (
+	github.com/gorilla/websocket v1.4.2
+	github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/stripedpajamas/resl/models => ../models
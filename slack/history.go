@@ -0,0 +1,87 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// maxHistoryCode is how much of each run's code the history shows
+const maxHistoryCode = 300
+
+// HistoryEntry is a past run listed by HistoryMessage
+type HistoryEntry struct {
+	RunID  string
+	Props  models.LanguageProperties
+	Code   string
+	Output models.CodeOutput
+	RanAt  time.Time
+}
+
+// describes how the run went in a few words
+func historyStatus(output models.CodeOutput) string {
+	switch {
+	case output.CompileFailed():
+		return ":red_circle: *compilation failed*"
+	case output.Run != nil:
+		return StatusLine(*output.Run)
+	default:
+		return "no output"
+	}
+}
+
+// renders a timestamp in the reader's own timezone
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.UTC().Format(time.RFC1123))
+}
+
+// HistoryMessage lists the runs, newest first, each with buttons to run it
+// again or open it in the modal
+func HistoryMessage(entries []HistoryEntry) Response {
+	if len(entries) == 0 {
+		return Response{Text: "You haven't run any code yet"}
+	}
+
+	var text strings.Builder
+	text.WriteString("Your recent runs\n")
+
+	blocks := []Block{
+		Block{
+			Type: "header",
+			Text: &ViewOptions{
+				Type: plainTextType,
+				Text: "Your recent runs",
+			},
+		},
+	}
+
+	for _, entry := range entries {
+		label := LanguageLabel(entry.Props)
+		text.WriteString(label + ": " + Clip(entry.Code, maxHistoryCode) + "\n")
+
+		// the run is looked up again when a button is pressed, so its code
+		// never has to fit in the button
+		value, _ := json.Marshal(RunAction{RunID: entry.RunID})
+
+		blocks = append(blocks,
+			Block{Type: "divider"},
+			codeSection(label, Clip(entry.Code, maxHistoryCode)),
+			ContextBlock(slackDate(entry.RanAt)+" · "+historyStatus(entry.Output)),
+			Block{
+				Type: "actions",
+				Elements: []interface{}{
+					button(RerunActionID, "Re-run", string(value)),
+					button(EditRerunActionID, "Open in modal", string(value)),
+				},
+			},
+		)
+	}
+
+	return Response{
+		Text:   text.String(),
+		Blocks: blocks,
+	}
+}
@@ -0,0 +1,72 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+func TestHistoryMessage(t *testing.T) {
+	python := models.LanguageProperties{Name: "Python", ShortName: "py3", Version: "3.7"}
+	ranAt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		entries []HistoryEntry
+		text    string
+		runs    []string
+	}{
+		{
+			name: "no runs",
+			text: "You haven't run any code yet",
+		},
+		{
+			name: "runs",
+			entries: []HistoryEntry{
+				{RunID: "R2", Props: python, Code: "print(2)", RanAt: ranAt, Output: models.CodeOutput{Run: &models.ExecutionResult{}}},
+				{RunID: "R1", Props: python, Code: "print(1)", RanAt: ranAt, Output: models.CodeOutput{Compile: &models.ExecutionResult{ExitCode: 1}}},
+			},
+			text: "Your recent runs\nPython (3.7): print(2)\nPython (3.7): print(1)\n",
+			runs: []string{"R2", "R1"},
+		},
+		{
+			name:    "long code",
+			entries: []HistoryEntry{{RunID: "R1", Props: python, Code: strings.Repeat("x", 2*maxHistoryCode), RanAt: ranAt}},
+			text:    "Your recent runs\nPython (3.7): " + Clip(strings.Repeat("x", 2*maxHistoryCode), maxHistoryCode) + "\n",
+			runs:    []string{"R1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := HistoryMessage(test.entries)
+			if message.ResponseType != "" {
+				t.Errorf("expected the history to be private, got %q", message.ResponseType)
+			}
+			if message.Text != test.text {
+				t.Errorf("expected text %q, got %q", test.text, message.Text)
+			}
+
+			var runs []string
+			for _, block := range message.Blocks {
+				if block.Type != "actions" {
+					continue
+				}
+				for _, element := range block.Elements {
+					action, err := ParseRunAction(element.(Element).Value)
+					if err != nil || action.Code != "" || action.Language != "" {
+						t.Errorf("expected buttons to carry only the run id, got %q", element.(Element).Value)
+					}
+					if element.(Element).ActionID == RerunActionID {
+						runs = append(runs, action.RunID)
+					}
+				}
+			}
+			if strings.Join(runs, ",") != strings.Join(test.runs, ",") {
+				t.Errorf("expected buttons for %v, got %v", test.runs, runs)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package slack
+
+import (
+	"strings"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+// LanguagesMessage lists the configured languages with the short name and
+// aliases to run each with and its example code
+func LanguagesMessage(languages models.LanguageConfig, slashCommand string) Response {
+	var b strings.Builder
+	b.WriteString("*Supported languages*\n")
+
+	for _, props := range SortedLanguages(languages) {
+		b.WriteString("• `" + props.ShortName + "` " + LanguageLabel(props))
+		if len(props.Aliases) > 0 {
+			b.WriteString(", also `" + strings.Join(props.Aliases, "`, `") + "`")
+		}
+
+		switch {
+		case props.Placeholder == "":
+			b.WriteString("\n")
+		case strings.Contains(props.Placeholder, "\n"):
+			b.WriteString("\n")
+			writeBlock(&b, props.Placeholder)
+		default:
+			b.WriteString(" · `" + escapeString(props.Placeholder) + "`\n")
+		}
+	}
+
+	b.WriteString("Run code with `" + slashCommand + " <language> <code>`, or tag its code block with the language")
+
+	return Response{Text: b.String()}
+}
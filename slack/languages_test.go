@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+func TestLanguagesMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages models.LanguageConfig
+		want      string
+	}{
+		{
+			name: "none",
+			want: "*Supported languages*\n" +
+				"Run code with `/resl <language> <code>`, or tag its code block with the language",
+		},
+		{
+			name: "sorted with aliases and examples",
+			languages: models.LanguageConfig{
+				"py3": {Name: "Python", ShortName: "py3", Version: "3.7", Aliases: []string{"python", "py"}, Placeholder: "print(`hi`)"},
+				"c":   {Name: "C", ShortName: "c", Placeholder: "int main() {\n  return 0;\n}"},
+				"bf":  {Name: "Brainfuck", ShortName: "bf"},
+			},
+			want: "*Supported languages*\n" +
+				"• `bf` Brainfuck\n" +
+				"• `c` C\n```int main() {\n  return 0;\n}```\n" +
+				"• `py3` Python (3.7), also `python`, `py` · `print(\\`hi\\`)`\n" +
+				"Run code with `/resl <language> <code>`, or tag its code block with the language",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := LanguagesMessage(test.languages, "/resl")
+			if message.ResponseType != "" {
+				t.Errorf("expected the list to be private, got %q", message.ResponseType)
+			}
+			if message.Text != test.want {
+				t.Errorf("expected\n%s\ngot\n%s", test.want, message.Text)
+			}
+		})
+	}
+}
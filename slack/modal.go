@@ -1,31 +1,193 @@
 package slack
 
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
 // CodeBlockName represents the name of the modal code block
 const CodeBlockName = "main_code_block"
 
 // LanguageBlockName represents the language selector name
 const LanguageBlockName = "language_block"
 
+// StdinBlockName represents the name of the modal program input block
+const StdinBlockName = "stdin_block"
+
 // ConversationSelectBlockName represents the name of the modal convo select block
 const ConversationSelectBlockName = "response_block"
 
 // CodeActionID represents the name of the code element action
 const CodeActionID = "code_input"
 
+// StdinActionID represents the name of the program input element action
+const StdinActionID = "stdin_input"
+
 // LanguageActionID represents the action of the language selector input
 const LanguageActionID = "select_language"
 
+// MaxInputLength is the most characters slack allows in a plain text input,
+// so also the most code or program input a modal can be prefilled with
+const MaxInputLength = 3000
+
+// maxSelectOptions is the most options or option groups slack allows in a select
+const maxSelectOptions = 100
+
 const plainTextType = "plain_text"
 const inputType = "input"
 
-// GenerateRESLModal returns a payload that contains a language-specific resl modal
-func GenerateRESLModal(languageName string, languageShortName string, placeholder string) ModalDefinition {
-	if placeholder == "" {
-		placeholder = "Code goes here"
+// LanguageLabel returns the display name of a language including its version
+func LanguageLabel(props models.LanguageProperties) string {
+	if props.Version == "" {
+		return props.Name
+	}
+	return props.Name + " (" + props.Version + ")"
+}
+
+// SortedLanguages returns the configured languages ordered by name, then version
+func SortedLanguages(languages models.LanguageConfig) []models.LanguageProperties {
+	sorted := make([]models.LanguageProperties, 0, len(languages))
+	for _, props := range languages {
+		sorted = append(sorted, props)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		if sorted[i].Version != sorted[j].Version {
+			return sorted[i].Version < sorted[j].Version
+		}
+		return sorted[i].ShortName < sorted[j].ShortName
+	})
+
+	return sorted
+}
+
+// languageSelectElement builds the language dropdown from the configured
+// languages. Past slack's option limit consecutive languages are packed into
+// groups labelled with the names they run between
+func languageSelectElement(languages models.LanguageConfig) *Element {
+	element := &Element{
+		Type:     "static_select",
+		ActionID: LanguageActionID,
+	}
+
+	sorted := SortedLanguages(languages)
+	if len(sorted) <= maxSelectOptions {
+		for _, props := range sorted {
+			element.Options = append(element.Options, languageOption(props))
+		}
+		return element
 	}
 
-	if languageName == "" {
-		languageName = "Code"
+	if max := maxSelectOptions * maxSelectOptions; len(sorted) > max {
+		log.Printf("Too many languages for the modal, dropping %d\n", len(sorted)-max)
+		sorted = sorted[:max]
+	}
+
+	for start := 0; start < len(sorted); start += maxSelectOptions {
+		end := start + maxSelectOptions
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+
+		label := sorted[start].Name
+		if last := sorted[end-1].Name; last != label {
+			label += " – " + last
+		}
+
+		group := OptionGroup{
+			Label: ViewOptions{
+				Type: plainTextType,
+				Text: label,
+			},
+		}
+		for _, props := range sorted[start:end] {
+			group.Options = append(group.Options, languageOption(props))
+		}
+		element.OptionGroups = append(element.OptionGroups, group)
+	}
+
+	return element
+}
+
+func languageOption(props models.LanguageProperties) SelectOption {
+	return SelectOption{
+		Text: ViewOptions{
+			Type: plainTextType,
+			Text: LanguageLabel(props),
+		},
+		Value: props.ShortName,
+	}
+}
+
+// ModalPrefill is the content a resl modal starts with, e.g. from a previous run
+type ModalPrefill struct {
+	Code string
+	// Language is preselected in the language dropdown
+	Language string
+	Stdin    string
+	// ChannelID and ThreadTS are the thread the modal was opened from; the
+	// result is posted there when the user runs the code in that channel
+	ChannelID string
+	ThreadTS  string
+}
+
+// Fits reports whether the prefill's code and program input fit in the
+// modal's inputs
+func (p ModalPrefill) Fits() bool {
+	return utf8.RuneCountInString(p.Code) <= MaxInputLength && utf8.RuneCountInString(p.Stdin) <= MaxInputLength
+}
+
+// returns what an input starts with, leaving out text that slack would reject
+// the whole modal for
+func initialValue(s string) string {
+	if length := utf8.RuneCountInString(s); length > MaxInputLength {
+		log.Printf("Prefill is %d characters, too long for the modal, leaving it out\n", length)
+		return ""
+	}
+	return s
+}
+
+// ModalMetadata is carried in the private metadata of a resl modal so its
+// submission knows what the modal was opened for
+type ModalMetadata struct {
+	Language  string `json:"language,omitempty"`
+	ChannelID string `json:"channel,omitempty"`
+	ThreadTS  string `json:"thread_ts,omitempty"`
+}
+
+// ParseModalMetadata reads a resl modal's private metadata. Modals opened by
+// older versions carry only the language short name
+func ParseModalMetadata(privateMetadata string) ModalMetadata {
+	var metadata ModalMetadata
+	if err := json.Unmarshal([]byte(privateMetadata), &metadata); err != nil {
+		return ModalMetadata{Language: privateMetadata}
+	}
+	return metadata
+}
+
+// GenerateRESLModal returns a payload that contains a resl modal. When
+// languageShortName names a configured language the modal is specific to it,
+// otherwise it offers a dropdown of every configured language. The inputs
+// start with the prefill's content, which is left out if it doesn't fit
+func GenerateRESLModal(languages models.LanguageConfig, languageShortName string, prefill ModalPrefill) ModalDefinition {
+	placeholder := "Code goes here"
+	languageName := "Code"
+
+	props, found := languages[languageShortName]
+	if !found {
+		languageShortName = ""
+	} else {
+		languageName = props.Name
+		if props.Placeholder != "" {
+			placeholder = props.Placeholder
+		}
 	}
 
 	blocks := []Block{
@@ -33,9 +195,11 @@ func GenerateRESLModal(languageName string, languageShortName string, placeholde
 			BlockID: CodeBlockName,
 			Type:    inputType,
 			Element: &Element{
-				Type:      "plain_text_input",
-				ActionID:  CodeActionID,
-				Multiline: true,
+				Type:         "plain_text_input",
+				ActionID:     CodeActionID,
+				Multiline:    true,
+				MaxLength:    MaxInputLength,
+				InitialValue: initialValue(prefill.Code),
 				Placeholder: &ViewOptions{
 					Type: plainTextType,
 					Text: placeholder,
@@ -50,6 +214,26 @@ func GenerateRESLModal(languageName string, languageShortName string, placeholde
 				Text: "Wrapping your code in backticks is optional",
 			},
 		},
+		Block{
+			BlockID:  StdinBlockName,
+			Type:     inputType,
+			Optional: true,
+			Element: &Element{
+				Type:         "plain_text_input",
+				ActionID:     StdinActionID,
+				Multiline:    true,
+				MaxLength:    MaxInputLength,
+				InitialValue: initialValue(prefill.Stdin),
+			},
+			Label: &ViewOptions{
+				Type: plainTextType,
+				Text: "Program input",
+			},
+			Hint: &ViewOptions{
+				Type: plainTextType,
+				Text: "Passed to your program on stdin",
+			},
+		},
 		Block{
 			BlockID:  ConversationSelectBlockName,
 			Type:     inputType,
@@ -67,8 +251,13 @@ func GenerateRESLModal(languageName string, languageShortName string, placeholde
 		},
 	}
 
-	// Todo make options dynamic based on supported lanugages in languages.json
 	if languageShortName == "" {
+		element := languageSelectElement(languages)
+		if props, found := languages[prefill.Language]; found {
+			option := languageOption(props)
+			element.InitialOption = &option
+		}
+
 		blocks = append(blocks, Block{
 			BlockID: LanguageBlockName,
 			Type:    inputType,
@@ -76,36 +265,19 @@ func GenerateRESLModal(languageName string, languageShortName string, placeholde
 				Type: plainTextType,
 				Text: "Select a coding language",
 			},
-			Element: &Element{
-				Type:     "static_select",
-				ActionID: LanguageActionID,
-				Options: []SelectOption{
-					SelectOption{
-						Text: ViewOptions{
-							Type: plainTextType,
-							Text: "JavaScript",
-						},
-						Value: "js",
-					},
-					SelectOption{
-						Text: ViewOptions{
-							Type: plainTextType,
-							Text: "Python",
-						},
-						Value: "py",
-					},
-					SelectOption{
-						Text: ViewOptions{
-							Type: plainTextType,
-							Text: "Python3",
-						},
-						Value: "py3",
-					},
-				},
-			},
+			Element: element,
 		})
 	}
 
+	privateMetadata, err := json.Marshal(ModalMetadata{
+		Language:  languageShortName,
+		ChannelID: prefill.ChannelID,
+		ThreadTS:  prefill.ThreadTS,
+	})
+	if err != nil {
+		log.Printf("Error while serializing modal metadata: %s\n", err.Error())
+	}
+
 	return ModalDefinition{
 		Type: "modal",
 		Title: &ViewOptions{
@@ -123,7 +295,7 @@ func GenerateRESLModal(languageName string, languageShortName string, placeholde
 			Text:  "Cancel",
 			Emoji: true,
 		},
-		PrivateMetadata: languageShortName,
+		PrivateMetadata: string(privateMetadata),
 		Blocks:          blocks,
 	}
 }
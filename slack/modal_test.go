@@ -0,0 +1,124 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+func TestGenerateRESLModalPrefill(t *testing.T) {
+	languages := models.LanguageConfig{
+		"py3": {Name: "Python", ShortName: "py3", Version: "3.7"},
+	}
+	long := strings.Repeat("é", MaxInputLength+1)
+
+	tests := []struct {
+		name    string
+		prefill ModalPrefill
+		fits    bool
+		code    string
+		stdin   string
+	}{
+		{
+			name:    "short",
+			prefill: ModalPrefill{Code: "print(input())", Stdin: "hello"},
+			fits:    true,
+			code:    "print(input())",
+			stdin:   "hello",
+		},
+		{
+			name:    "at the limit",
+			prefill: ModalPrefill{Code: strings.Repeat("é", MaxInputLength)},
+			fits:    true,
+			code:    strings.Repeat("é", MaxInputLength),
+		},
+		{
+			name:    "code too long",
+			prefill: ModalPrefill{Code: long, Stdin: "hello"},
+			stdin:   "hello",
+		},
+		{
+			name:    "input too long",
+			prefill: ModalPrefill{Code: "print(input())", Stdin: long},
+			code:    "print(input())",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fits := test.prefill.Fits(); fits != test.fits {
+				t.Errorf("expected Fits() = %v, got %v", test.fits, fits)
+			}
+
+			inputs := map[string]*Element{}
+			for _, block := range GenerateRESLModal(languages, "py3", test.prefill).Blocks {
+				inputs[block.BlockID] = block.Element
+			}
+
+			for id, want := range map[string]string{CodeBlockName: test.code, StdinBlockName: test.stdin} {
+				input := inputs[id]
+				if input.MaxLength != MaxInputLength {
+					t.Errorf("expected %s to be limited to %d characters, got %d", id, MaxInputLength, input.MaxLength)
+				}
+				if input.InitialValue != want {
+					t.Errorf("expected %s to start with %d characters, got %d", id, len([]rune(want)), len([]rune(input.InitialValue)))
+				}
+			}
+		})
+	}
+}
+
+func TestLanguageSelectElementGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		languages int
+		options   int
+		groups    []int
+	}{
+		{name: "few", languages: 3, options: 3},
+		{name: "at the limit", languages: 100, options: 100},
+		{name: "one past the limit", languages: 101, groups: []int{100, 1}},
+		{name: "many", languages: 250, groups: []int{100, 100, 50}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// every language has a name of its own
+			languages := models.LanguageConfig{}
+			for i := 0; i < test.languages; i++ {
+				shortName := fmt.Sprintf("lang%03d", i)
+				languages[shortName] = models.LanguageProperties{Name: fmt.Sprintf("Language %03d", i), ShortName: shortName}
+			}
+
+			element := languageSelectElement(languages)
+			if len(element.Options) != test.options {
+				t.Errorf("expected %d options, got %d", test.options, len(element.Options))
+			}
+			if len(element.OptionGroups) != len(test.groups) {
+				t.Fatalf("expected %d groups, got %d", len(test.groups), len(element.OptionGroups))
+			}
+
+			seen := map[string]bool{}
+			for _, option := range element.Options {
+				seen[option.Value] = true
+			}
+			for i, group := range element.OptionGroups {
+				if len(group.Options) != test.groups[i] {
+					t.Errorf("expected %d options in group %d, got %d", test.groups[i], i, len(group.Options))
+				}
+				first, last := group.Options[0].Text.Text, group.Options[len(group.Options)-1].Text.Text
+				if label := group.Label.Text; !strings.HasPrefix(label, first) || !strings.HasSuffix(label, last) {
+					t.Errorf("expected group %d to be labelled from %s to %s, got %s", i, first, last, label)
+				}
+				for _, option := range group.Options {
+					seen[option.Value] = true
+				}
+			}
+			if len(seen) != test.languages {
+				t.Errorf("expected all %d languages in the dropdown, got %d", test.languages, len(seen))
+			}
+		})
+	}
+}
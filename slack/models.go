@@ -36,25 +36,39 @@ type SelectOption struct {
 	Value string      `json:"value,omitempty"`
 }
 
-// Element represents an element inside the modal blocks
+// OptionGroup represents a labelled group of select element list items
+type OptionGroup struct {
+	Label   ViewOptions    `json:"label,omitempty"`
+	Options []SelectOption `json:"options,omitempty"`
+}
+
+// Element represents an element inside the modal blocks or a message button
 type Element struct {
 	ActionID                     string         `json:"action_id,omitempty"`
 	Type                         string         `json:"type,omitempty"`
+	Text                         *ViewOptions   `json:"text,omitempty"`
+	Value                        string         `json:"value,omitempty"`
+	InitialValue                 string         `json:"initial_value,omitempty"`
+	InitialOption                *SelectOption  `json:"initial_option,omitempty"`
 	DefaultToCurrentConversation bool           `json:"default_to_current_conversation,omitempty"`
 	ResponseURLEnabled           bool           `json:"response_url_enabled,omitempty"`
 	Multiline                    bool           `json:"multiline,omitempty"`
+	MaxLength                    int            `json:"max_length,omitempty"`
 	Placeholder                  *ViewOptions   `json:"placeholder,omitempty"`
 	Options                      []SelectOption `json:"options,omitempty"`
+	OptionGroups                 []OptionGroup  `json:"option_groups,omitempty"`
 }
 
-// Block represents the different blocks in the modal
+// Block represents the different blocks in modals and messages
 type Block struct {
-	BlockID  string       `json:"block_id,omitempty"`
-	Type     string       `json:"type,omitempty"`
-	Element  *Element     `json:"element,omitempty"`
-	Label    *ViewOptions `json:"label,omitempty"`
-	Hint     *ViewOptions `json:"hint,omitempty"`
-	Optional bool         `json:"optional,omitempty"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Type     string        `json:"type,omitempty"`
+	Text     *ViewOptions  `json:"text,omitempty"`
+	Element  *Element      `json:"element,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+	Label    *ViewOptions  `json:"label,omitempty"`
+	Hint     *ViewOptions  `json:"hint,omitempty"`
+	Optional bool          `json:"optional,omitempty"`
 }
 
 // ModalDefinition represents the slack modal data
@@ -70,9 +84,11 @@ type ModalDefinition struct {
 
 // Response contains the properties necessary to respond to a message
 type Response struct {
-	ResponseAction string `json:"response_action,omitempty"`
-	ResponseType   string `json:"response_type,omitempty"`
-	Text           string `json:"text,omitempty"`
+	ResponseAction string  `json:"response_action,omitempty"`
+	ResponseType   string  `json:"response_type,omitempty"`
+	Text           string  `json:"text,omitempty"`
+	Blocks         []Block `json:"blocks,omitempty"`
+	ThreadTS       string  `json:"thread_ts,omitempty"`
 }
 
 // User represents a slack user
@@ -82,6 +98,18 @@ type User struct {
 	Name     string `json:"name"`
 }
 
+// Team represents a slack workspace
+type Team struct {
+	ID     string `json:"id"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// Enterprise represents a slack enterprise org
+type Enterprise struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
 // ResponseURL represents a slack response url object
 type ResponseURL struct {
 	ActionID  string `json:"action_id"`
@@ -95,6 +123,53 @@ type ModalRequest struct {
 	View         ModalDefinition `json:"view"`
 	User         User            `json:"user"`
 	ResponseURLS []ResponseURL   `json:"response_urls"`
+	Team         *Team           `json:"team,omitempty"`
+	Enterprise   *Enterprise     `json:"enterprise,omitempty"`
+}
+
+// Channel represents a slack channel
+type Channel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Action represents a button press or other interaction with a block element
+type Action struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+}
+
+// Message represents a slack message
+type Message struct {
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// InteractionPayload represents the common fields of the interaction payloads
+// slack sends, e.g. view_submission, block_actions and message_action
+type InteractionPayload struct {
+	Type        string      `json:"type"`
+	CallbackID  string      `json:"callback_id"`
+	TriggerID   string      `json:"trigger_id"`
+	User        User        `json:"user"`
+	Team        Team        `json:"team"`
+	Enterprise  *Enterprise `json:"enterprise"`
+	Channel     Channel     `json:"channel"`
+	Message     Message     `json:"message"`
+	ResponseURL string      `json:"response_url"`
+	Actions     []Action    `json:"actions"`
+}
+
+// EnterpriseID returns the id of the enterprise org the interaction came from, if any
+func (p InteractionPayload) EnterpriseID() string {
+	if p.Enterprise == nil {
+		return ""
+	}
+	return p.Enterprise.ID
 }
 
 // Request represents the incoming request body from Slack
@@ -102,6 +177,7 @@ type Request struct {
 	APIAppID            string `schema:"api_app_id"`
 	ChannelID           string `schema:"channel_id"`
 	ChannelName         string `schema:"channel_name"`
+	EnterpriseID        string `schema:"enterprise_id"`
 	AppCommand          string `schema:"command"`
 	IsEnterpriseInstall bool   `schema:"is_enterprise_install"`
 	ResponseURL         string `schema:"response_url"`
@@ -113,4 +189,8 @@ type Request struct {
 	UserID              string `schema:"user_id"`
 	UserName            string `schema:"user_name"`
 	ModalPayload        string `schema:"payload"`
+	// Stdin is the program input from the modal; slash commands carry it in Text
+	Stdin string `schema:"-"`
+	// ThreadTS is the thread a modal submission's result belongs in
+	ThreadTS string `schema:"-"`
 }
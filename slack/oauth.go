@@ -0,0 +1,197 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuthorizeURL is where users are sent to install the app
+const DefaultAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+
+// ErrInstallationNotFound is returned when the app isn't installed for a team
+var ErrInstallationNotFound = errors.New("no installation found for team")
+
+// Installation is the app's install in a workspace, or in a whole enterprise
+// org when IsEnterpriseInstall is set
+type Installation struct {
+	TeamID              string    `json:"teamId,omitempty"`
+	TeamName            string    `json:"teamName,omitempty"`
+	EnterpriseID        string    `json:"enterpriseId,omitempty"`
+	IsEnterpriseInstall bool      `json:"isEnterpriseInstall,omitempty"`
+	AppID               string    `json:"appId,omitempty"`
+	BotUserID           string    `json:"botUserId,omitempty"`
+	BotToken            string    `json:"botToken"`
+	Scope               string    `json:"scope,omitempty"`
+	InstalledBy         string    `json:"installedBy,omitempty"`
+	InstalledAt         time.Time `json:"installedAt"`
+}
+
+// InstallationStore keeps the bot tokens of the workspaces the app is installed in
+type InstallationStore interface {
+	SaveInstallation(ctx context.Context, installation Installation) error
+	// FindInstallation returns the installation for the team, or for its
+	// enterprise org when the app was installed org-wide. It returns
+	// ErrInstallationNotFound when there is neither
+	FindInstallation(ctx context.Context, teamID, enterpriseID string) (Installation, error)
+}
+
+// MemoryInstallationStore is an InstallationStore that only lasts as long as
+// the process, for local development and tests
+type MemoryInstallationStore struct {
+	mu            sync.Mutex
+	installations map[string]Installation
+}
+
+// InstallationKey is what an InstallationStore keeps the installation under:
+// org-wide installs by enterprise and the rest by team
+func InstallationKey(installation Installation) string {
+	if installation.IsEnterpriseInstall {
+		return "E:" + installation.EnterpriseID
+	}
+	return "T:" + installation.TeamID
+}
+
+// FindInstallationByKey looks up the team's installation, falling back to
+// its org's, with get returning what is kept under an InstallationKey and
+// whether there was anything
+func FindInstallationByKey(teamID, enterpriseID string, get func(key string) (Installation, bool, error)) (Installation, error) {
+	var keys []string
+	if teamID != "" {
+		keys = append(keys, "T:"+teamID)
+	}
+	if enterpriseID != "" {
+		keys = append(keys, "E:"+enterpriseID)
+	}
+
+	for _, key := range keys {
+		installation, found, err := get(key)
+		if err != nil || found {
+			return installation, err
+		}
+	}
+	return Installation{}, ErrInstallationNotFound
+}
+
+// SaveInstallation stores the installation, replacing any earlier one
+func (s *MemoryInstallationStore) SaveInstallation(ctx context.Context, installation Installation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.installations == nil {
+		s.installations = make(map[string]Installation)
+	}
+	s.installations[InstallationKey(installation)] = installation
+	return nil
+}
+
+// FindInstallation returns the team's installation, falling back to its org's
+func (s *MemoryInstallationStore) FindInstallation(ctx context.Context, teamID, enterpriseID string) (Installation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return FindInstallationByKey(teamID, enterpriseID, func(key string) (Installation, bool, error) {
+		installation, found := s.installations[key]
+		return installation, found, nil
+	})
+}
+
+// ClientForTeam returns a copy of base that uses the bot token installed for
+// the team. Without a store base is returned as is; with one, a team without
+// an installation gets ErrInstallationNotFound, since base's token belongs to
+// some other workspace
+func ClientForTeam(ctx context.Context, base *Client, store InstallationStore, teamID, enterpriseID string) (*Client, error) {
+	if store == nil || (teamID == "" && enterpriseID == "") {
+		return base, nil
+	}
+
+	installation, err := store.FindInstallation(ctx, teamID, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := *base
+	client.Token = installation.BotToken
+	return &client, nil
+}
+
+// InstallURL returns the link that starts installing the app. state is
+// echoed back to the redirect url so the callback can be verified
+func InstallURL(clientID string, scopes []string, redirectURL, state string) string {
+	query := url.Values{
+		"client_id": {clientID},
+		"scope":     {strings.Join(scopes, ",")},
+		"state":     {state},
+	}
+	if redirectURL != "" {
+		query.Set("redirect_uri", redirectURL)
+	}
+
+	return DefaultAuthorizeURL + "?" + query.Encode()
+}
+
+type oauthV2Response struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+	BotUserID   string `json:"bot_user_id"`
+	AppID       string `json:"app_id"`
+	Team        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+	Enterprise *struct {
+		ID string `json:"id"`
+	} `json:"enterprise"`
+	IsEnterpriseInstall bool `json:"is_enterprise_install"`
+	AuthedUser          struct {
+		ID string `json:"id"`
+	} `json:"authed_user"`
+}
+
+// OAuthV2Access exchanges the code slack sent to the redirect url for the
+// installation's bot token
+func (c *Client) OAuthV2Access(ctx context.Context, clientID, clientSecret, code, redirectURL string) (Installation, error) {
+	args := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"code":          {code},
+	}
+	if redirectURL != "" {
+		args.Set("redirect_uri", redirectURL)
+	}
+
+	body, err := c.call(ctx, "oauth.v2.access", c.methodURL("oauth.v2.access"), formContentType, []byte(args.Encode()), false)
+	if err != nil {
+		return Installation{}, err
+	}
+
+	var access oauthV2Response
+	if err = json.Unmarshal(body, &access); err != nil {
+		return Installation{}, err
+	}
+	if access.TokenType != "bot" || access.AccessToken == "" {
+		return Installation{}, errors.New("slack returned no bot token")
+	}
+
+	installation := Installation{
+		TeamID:              access.Team.ID,
+		TeamName:            access.Team.Name,
+		IsEnterpriseInstall: access.IsEnterpriseInstall,
+		AppID:               access.AppID,
+		BotUserID:           access.BotUserID,
+		BotToken:            access.AccessToken,
+		Scope:               access.Scope,
+		InstalledBy:         access.AuthedUser.ID,
+		InstalledAt:         time.Now().UTC(),
+	}
+	if access.Enterprise != nil {
+		installation.EnterpriseID = access.Enterprise.ID
+	}
+
+	return installation, nil
+}
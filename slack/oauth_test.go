@@ -0,0 +1,88 @@
+package slack
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryInstallationStore(t *testing.T) {
+	store := &MemoryInstallationStore{}
+	ctx := context.Background()
+
+	for _, installation := range []Installation{
+		{TeamID: "T1", BotToken: "xoxb-team"},
+		{TeamID: "T2", EnterpriseID: "E1", IsEnterpriseInstall: true, BotToken: "xoxb-org"},
+	} {
+		if err := store.SaveInstallation(ctx, installation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		teamID       string
+		enterpriseID string
+		token        string
+	}{
+		{name: "team install", teamID: "T1", token: "xoxb-team"},
+		{name: "team install in an org", teamID: "T1", enterpriseID: "E1", token: "xoxb-team"},
+		{name: "org-wide install", teamID: "T3", enterpriseID: "E1", token: "xoxb-org"},
+		{name: "org only", enterpriseID: "E1", token: "xoxb-org"},
+		{name: "org install isn't the installing team's", teamID: "T2"},
+		{name: "unknown team", teamID: "T9", enterpriseID: "E9"},
+		{name: "nothing to look up"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			installation, err := store.FindInstallation(ctx, test.teamID, test.enterpriseID)
+			if test.token == "" {
+				if err != ErrInstallationNotFound {
+					t.Errorf("expected ErrInstallationNotFound, got %+v, %v", installation, err)
+				}
+				return
+			}
+			if err != nil || installation.BotToken != test.token {
+				t.Errorf("expected %s, got %+v, %v", test.token, installation, err)
+			}
+		})
+	}
+}
+
+func TestClientForTeam(t *testing.T) {
+	ctx := context.Background()
+	base := &Client{Token: "xoxb-base"}
+
+	installations := &MemoryInstallationStore{}
+	if err := installations.SaveInstallation(ctx, Installation{TeamID: "T1", BotToken: "xoxb-team"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		store  InstallationStore
+		teamID string
+		token  string
+		err    error
+	}{
+		{name: "no store", teamID: "T1", token: "xoxb-base"},
+		{name: "installed team", store: installations, teamID: "T1", token: "xoxb-team"},
+		{name: "team without an installation", store: installations, teamID: "T2", err: ErrInstallationNotFound},
+		{name: "no team", store: installations, token: "xoxb-base"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := ClientForTeam(ctx, base, test.store, test.teamID, "")
+			if err != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if err == nil && client.Token != test.token {
+				t.Errorf("expected token %s, got %s", test.token, client.Token)
+			}
+		})
+	}
+	if base.Token != "xoxb-base" {
+		t.Errorf("expected base to be left alone, got %s", base.Token)
+	}
+}
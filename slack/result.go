@@ -0,0 +1,226 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stripedpajamas/resl/models"
+)
+
+const mrkdwnType = "mrkdwn"
+
+// maxSectionText is the most characters slack allows in a section's text
+const maxSectionText = 3000
+
+// maxHeaderText is the most characters slack allows in a header's text
+const maxHeaderText = 150
+
+func escapeString(s string) string {
+	return strings.ReplaceAll(s, "`", "\\`")
+}
+
+// Clip cuts text down to at most max bytes without splitting a character,
+// ending it with … when it was cut
+func Clip(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	end := max - len("…")
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "…"
+}
+
+// wraps text in ```<text>``` on its own line
+func writeBlock(b *strings.Builder, text string) {
+	b.WriteString("```")
+	b.WriteString(escapeString(text))
+	b.WriteString("```")
+	b.WriteString("\n")
+}
+
+// StatusLine describes how a process finished, e.g. ":red_circle: *exit 1* · 12ms",
+// noting when the runner didn't keep all of its output
+func StatusLine(result models.ExecutionResult) string {
+	var status string
+	switch {
+	case result.TimedOut:
+		status = ":hourglass: *timed out*"
+	case result.Signal != "":
+		status = ":red_circle: *killed by " + result.Signal + "*"
+	case result.ExitCode != 0:
+		status = fmt.Sprintf(":red_circle: *exit %d*", result.ExitCode)
+	default:
+		status = ":white_check_mark: exit 0"
+	}
+	line := fmt.Sprintf("%s · %dms", status, result.DurationMs)
+	if result.Truncated {
+		line += " · _output truncated_"
+	}
+	return line
+}
+
+// ResultText formats the execution result as separate compiler, stdout and
+// stderr sections, each wrapped in ```<string>```, followed by the exit status.
+// It is the notification fallback for ResultBlocks
+func ResultText(request models.CodeProcessRequest, output models.CodeOutput) string {
+	var b strings.Builder
+	if request.Modal {
+		b.WriteString("<@" + request.UserID + ">\n")
+		b.WriteString("```")
+		b.WriteString(request.Code)
+		b.WriteString("```")
+		b.WriteString("\n")
+	}
+	if request.Modal && request.Stdin != "" {
+		b.WriteString("*stdin*\n")
+		writeBlock(&b, request.Stdin)
+	}
+
+	if compile := output.Compile; compile != nil {
+		diagnostics := compile.Stdout + compile.Stderr
+		if output.CompileFailed() {
+			b.WriteString("*Compilation failed* " + StatusLine(*compile) + "\n")
+			if diagnostics == "" {
+				diagnostics = "[No compiler output]"
+			}
+			writeBlock(&b, diagnostics)
+			return b.String()
+		}
+		if diagnostics != "" {
+			b.WriteString("*Compiler output*\n")
+			writeBlock(&b, diagnostics)
+		}
+	}
+
+	run := output.Run
+	if run == nil {
+		b.WriteString("[No output]")
+		return b.String()
+	}
+
+	if run.Stdout != "" || run.Stderr == "" {
+		stdout := run.Stdout
+		if stdout == "" {
+			stdout = "[No output]"
+		}
+		writeBlock(&b, stdout)
+	}
+	if run.Stderr != "" {
+		b.WriteString("*stderr*\n")
+		writeBlock(&b, run.Stderr)
+	}
+	b.WriteString(StatusLine(*run))
+
+	return b.String()
+}
+
+// a mrkdwn section with an optional bold title above a ```<text>``` block
+func codeSection(title, text string) Block {
+	prefix := ""
+	if title != "" {
+		prefix = "*" + title + "*\n"
+	}
+	// leave room for the title and the backticks around the text
+	text = Clip(escapeString(text), maxSectionText-len(prefix)-len("``````"))
+
+	return Block{
+		Type: "section",
+		Text: &ViewOptions{
+			Type: mrkdwnType,
+			Text: prefix + "```" + text + "```",
+		},
+	}
+}
+
+// ContextBlock returns a context line made of mrkdwn text
+func ContextBlock(text string) Block {
+	return Block{
+		Type: "context",
+		Elements: []interface{}{
+			ViewOptions{
+				Type: mrkdwnType,
+				Text: text,
+			},
+		},
+	}
+}
+
+// ResultBlocks renders the execution result as Block Kit blocks: a header
+// with the language and user, the source, the output, a context line with
+// the exit status and runtime and buttons to run the code again
+func ResultBlocks(request models.CodeProcessRequest, output models.CodeOutput) []Block {
+	return append(outputBlocks(request, output), resultActions(request)...)
+}
+
+// renders everything in a result message except the buttons
+func outputBlocks(request models.CodeProcessRequest, output models.CodeOutput) []Block {
+	header := LanguageLabel(request.Props)
+	if header == "" {
+		header = "Result"
+	}
+
+	blocks := []Block{
+		Block{
+			Type: "header",
+			Text: &ViewOptions{
+				Type: plainTextType,
+				Text: Clip(header, maxHeaderText),
+			},
+		},
+	}
+	if request.UserID != "" {
+		blocks = append(blocks, ContextBlock("Run by <@"+request.UserID+">"))
+	}
+
+	blocks = append(blocks, codeSection("", request.Code))
+	if request.Stdin != "" {
+		blocks = append(blocks, codeSection("stdin", request.Stdin))
+	}
+
+	if compile := output.Compile; compile != nil {
+		diagnostics := compile.Stdout + compile.Stderr
+		if output.CompileFailed() {
+			if diagnostics == "" {
+				diagnostics = "[No compiler output]"
+			}
+			return append(blocks,
+				codeSection("Compilation failed", diagnostics),
+				ContextBlock(StatusLine(*compile)),
+			)
+		}
+		if diagnostics != "" {
+			blocks = append(blocks, codeSection("Compiler output", diagnostics))
+		}
+	}
+
+	run := output.Run
+	if run == nil {
+		return append(blocks, codeSection("Output", "[No output]"))
+	}
+
+	if run.Stdout != "" || run.Stderr == "" {
+		stdout := run.Stdout
+		if stdout == "" {
+			stdout = "[No output]"
+		}
+		blocks = append(blocks, codeSection("Output", stdout))
+	}
+	if run.Stderr != "" {
+		blocks = append(blocks, codeSection("stderr", run.Stderr))
+	}
+
+	return append(blocks, ContextBlock(StatusLine(*run)))
+}
+
+// ResultMessage returns the execution result as Block Kit blocks with the
+// plain text version as the fallback
+func ResultMessage(request models.CodeProcessRequest, output models.CodeOutput) Response {
+	return Response{
+		Text:   ResultText(request, output),
+		Blocks: ResultBlocks(request, output),
+	}
+}
@@ -0,0 +1,34 @@
+package slack
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClip(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"short", "hello", 10, "hello"},
+		{"exact", "hello", 5, "hello"},
+		{"cut", "hello world", 8, "hello…"},
+		{"cut before a character", "aaaaaéé", 8, "aaaaa…"},
+		{"cut inside a character", "aaaaééé", 8, "aaaa…"},
+		{"cut inside an emoji", "ab👍👍", 8, "ab…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Clip(test.s, test.max)
+			if got != test.want {
+				t.Errorf("Clip(%q, %d) = %q; want %q", test.s, test.max, got, test.want)
+			}
+			if len(got) > test.max || !utf8.ValidString(got) {
+				t.Errorf("Clip(%q, %d) = %q is too long or not valid utf-8", test.s, test.max, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package slacktest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlashCommand describes a slash command invocation sent to the app
+type SlashCommand struct {
+	Command   string
+	Text      string
+	UserID    string
+	ChannelID string
+	TeamID    string
+	TriggerID string
+	// ResponseURL defaults to the fake's "default" response url
+	ResponseURL string
+}
+
+// Sign returns the X-Slack-Signature slack would send for a body at a time
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(fmt.Sprintf("v0:%d:%s", timestamp.Unix(), body)))
+	return "v0=" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// SignedHeaders returns the timestamp and signature headers for a body, with
+// lowercased names as API Gateway delivers them
+func (s *Server) SignedHeaders(body []byte) map[string]string {
+	now := time.Now()
+	return map[string]string{
+		"content-type":              "application/x-www-form-urlencoded",
+		"x-slack-request-timestamp": strconv.FormatInt(now.Unix(), 10),
+		"x-slack-signature":         Sign(s.SigningSecret, now, body),
+	}
+}
+
+// SlashCommandForm returns the form body slack posts for a slash command
+func (s *Server) SlashCommandForm(command SlashCommand) url.Values {
+	if command.Command == "" {
+		command.Command = "/resl"
+	}
+	if command.ResponseURL == "" {
+		command.ResponseURL = s.ResponseURL("default")
+	}
+	if command.TriggerID == "" {
+		command.TriggerID = "trigger-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return url.Values{
+		"command":      {command.Command},
+		"text":         {command.Text},
+		"user_id":      {command.UserID},
+		"channel_id":   {command.ChannelID},
+		"team_id":      {command.TeamID},
+		"trigger_id":   {command.TriggerID},
+		"response_url": {command.ResponseURL},
+	}
+}
+
+// InteractionForm returns the form body slack posts for an interaction such
+// as a view submission, with the payload serialized to json
+func InteractionForm(payload interface{}) (url.Values, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return url.Values{"payload": {string(data)}}, nil
+}
+
+// NewRequest returns a signed form POST to the app at appURL
+func (s *Server) NewRequest(appURL string, form url.Values) (*http.Request, error) {
+	body := form.Encode()
+
+	req, err := http.NewRequest("POST", appURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range s.SignedHeaders([]byte(body)) {
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,267 @@
+// Package slacktest provides a fake slack for end-to-end tests. It records
+// the web API calls, messages and response url posts made by the slack
+// package and can send signed slash commands and interactions to the app
+// under test
+package slacktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// APICall is a recorded call to a slack web API method
+type APICall struct {
+	Method string
+	Header http.Header
+	Body   []byte
+}
+
+// Upload is the content of a file uploaded through the external upload flow
+type Upload struct {
+	FileID  string
+	Content []byte
+}
+
+// Post is a recorded post to a response url
+type Post struct {
+	Name     string
+	Body     []byte
+	Response slack.Response
+}
+
+// Server is a fake slack backed by an httptest.Server. Web API methods answer
+// {"ok":true} unless overridden with Handle
+type Server struct {
+	*httptest.Server
+
+	// SigningSecret signs the requests the fake sends to the app
+	SigningSecret string
+	// Token, when set, is the bearer token web API calls must carry
+	Token string
+
+	mu          sync.Mutex
+	handlers    map[string]http.HandlerFunc
+	calls       []APICall
+	viewsOpened []slack.ModalRequest
+	messages    []slack.ChatMessage
+	posts       []Post
+	uploads     []Upload
+	fileCount   int
+	tsCount     int
+}
+
+// NewServer starts a fake slack using the given signing secret. Callers must
+// Close it when done
+func NewServer(signingSecret string) *Server {
+	s := &Server{
+		SigningSecret: signingSecret,
+		handlers:      make(map[string]http.HandlerFunc),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", s.handleAPI)
+	mux.HandleFunc("/response/", s.handleResponse)
+	mux.HandleFunc("/upload/", s.handleUpload)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// APIURL is the base web API URL of the fake
+func (s *Server) APIURL() string {
+	return s.URL + "/api/"
+}
+
+// Client returns a slack client that talks to the fake
+func (s *Server) Client() *slack.Client {
+	return &slack.Client{
+		BaseURL:    s.APIURL(),
+		Token:      s.Token,
+		HTTPClient: s.Server.Client(),
+	}
+}
+
+// ResponseURL returns a response url on the fake. Posts to it are recorded
+// under name
+func (s *Server) ResponseURL(name string) string {
+	return s.URL + "/response/" + name
+}
+
+// Handle overrides the fake's answer for a web API method, e.g. "views.open".
+// Calls are still recorded
+func (s *Server) Handle(method string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[method] = handler
+}
+
+// Calls returns the recorded calls to a web API method, oldest first
+func (s *Server) Calls(method string) []APICall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []APICall
+	for _, call := range s.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Uploads returns the files uploaded through the external upload flow
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Upload(nil), s.uploads...)
+}
+
+// ViewsOpened returns the modals opened through views.open, oldest first
+func (s *Server) ViewsOpened() []slack.ModalRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]slack.ModalRequest(nil), s.viewsOpened...)
+}
+
+// Messages returns the messages posted through chat.postMessage, oldest first
+func (s *Server) Messages() []slack.ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]slack.ChatMessage(nil), s.messages...)
+}
+
+// Posts returns the posts made to the named response url, oldest first
+func (s *Server) Posts(name string) []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var posts []Post
+	for _, post := range s.posts {
+		if post.Name == name {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.calls = append(s.calls, APICall{Method: method, Header: r.Header.Clone(), Body: body})
+	switch method {
+	case "views.open":
+		var modal slack.ModalRequest
+		if err := json.Unmarshal(body, &modal); err == nil {
+			s.viewsOpened = append(s.viewsOpened, modal)
+		}
+	case "chat.postMessage":
+		var message slack.ChatMessage
+		if err := json.Unmarshal(body, &message); err == nil {
+			s.messages = append(s.messages, message)
+		}
+	}
+	handler := s.handlers[method]
+	s.mu.Unlock()
+
+	r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+		return
+	}
+
+	switch method {
+	case "files.getUploadURLExternal":
+		s.mu.Lock()
+		s.fileCount++
+		fileID := "F" + strconv.Itoa(s.fileCount)
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":         true,
+			"file_id":    fileID,
+			"upload_url": s.URL + "/upload/" + fileID,
+		})
+	case "chat.postMessage":
+		var message slack.ChatMessage
+		json.Unmarshal(body, &message)
+		s.mu.Lock()
+		s.tsCount++
+		ts := "1600000000." + fmt.Sprintf("%06d", s.tsCount)
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":      true,
+			"channel": message.Channel,
+			"ts":      ts,
+		})
+	case "oauth.v2.access":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":           true,
+			"access_token": "xoxb-fake",
+			"token_type":   "bot",
+			"bot_user_id":  "UBOT",
+			"app_id":       "AFAKE",
+			"team":         map[string]string{"id": "TFAKE", "name": "Fake"},
+		})
+	case "files.info":
+		r.ParseForm()
+		fileID := r.Form.Get("file")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok": true,
+			"file": map[string]string{
+				"id":        fileID,
+				"permalink": s.URL + "/files/" + fileID,
+			},
+		})
+	default:
+		w.Write([]byte(`{"ok":true}`))
+	}
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.uploads = append(s.uploads, Upload{
+		FileID:  strings.TrimPrefix(r.URL.Path, "/upload/"),
+		Content: body,
+	})
+	s.mu.Unlock()
+
+	w.Write([]byte("OK - " + strconv.Itoa(len(body))))
+}
+
+func (s *Server) handleResponse(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	post := Post{
+		Name: strings.TrimPrefix(r.URL.Path, "/response/"),
+		Body: body,
+	}
+	json.Unmarshal(body, &post.Response)
+
+	s.mu.Lock()
+	s.posts = append(s.posts, post)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`))
+}
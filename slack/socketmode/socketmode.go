@@ -0,0 +1,242 @@
+// Package socketmode receives slash commands, interactions and events from
+// slack over a socket mode websocket, so the app needs no public endpoint
+package socketmode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// Envelope types sent over a socket mode connection
+const (
+	HelloType         = "hello"
+	DisconnectType    = "disconnect"
+	SlashCommandsType = "slash_commands"
+	InteractiveType   = "interactive"
+	EventsAPIType     = "events_api"
+)
+
+// DefaultPingInterval is how often the connection is pinged
+const DefaultPingInterval = 30 * time.Second
+
+// DefaultMaxBackoff caps the wait between reconnects
+const DefaultMaxBackoff = time.Minute
+
+// minBackoff is the wait before the first reconnect
+const minBackoff = time.Second
+
+// ErrLinkDisabled is returned by Run when socket mode is turned off for the app
+var ErrLinkDisabled = errors.New("socket mode is disabled for this app")
+
+// Envelope is a message slack sends over a socket mode connection
+type Envelope struct {
+	EnvelopeID string `json:"envelope_id"`
+	Type       string `json:"type"`
+	// Payload is the slash command, interaction or Events API request
+	Payload json.RawMessage `json:"payload"`
+	// AcceptsResponsePayload is set when the acknowledgement may carry the
+	// response slack would otherwise expect from an HTTP request
+	AcceptsResponsePayload bool   `json:"accepts_response_payload"`
+	RetryAttempt           int    `json:"retry_attempt"`
+	RetryReason            string `json:"retry_reason"`
+	// Reason explains a disconnect, e.g. "refresh_requested"
+	Reason string `json:"reason"`
+}
+
+type acknowledgement struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// Handler handles an envelope and returns the response payload to
+// acknowledge it with, if any
+type Handler func(ctx context.Context, envelope Envelope) (json.RawMessage, error)
+
+// Client keeps a socket mode connection to slack open, handing each envelope
+// to Handler and acknowledging it, and reconnects whenever the connection drops
+type Client struct {
+	// API opens connections; it must use an app-level token (xapp-...)
+	API     *slack.Client
+	Handler Handler
+	// PingInterval defaults to DefaultPingInterval. A connection that stays
+	// silent for three intervals is dropped
+	PingInterval time.Duration
+	// MaxBackoff defaults to DefaultMaxBackoff
+	MaxBackoff time.Duration
+}
+
+// New returns a client that opens connections with the app-level token
+func New(appToken string, handler Handler) *Client {
+	return &Client{
+		API:     slack.NewClient(appToken),
+		Handler: handler,
+	}
+}
+
+func (c *Client) pingInterval() time.Duration {
+	if c.PingInterval > 0 {
+		return c.PingInterval
+	}
+	return DefaultPingInterval
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	maxBackoff := c.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	wait := minBackoff
+	for i := 0; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Run connects to slack and handles envelopes until ctx is done, the app's
+// token is rejected or socket mode is disabled
+func (c *Client) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		connected, err := c.serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var apiErr *slack.APIError
+		if errors.Is(err, ErrLinkDisabled) || (errors.As(err, &apiErr) && !apiErr.Temporary()) {
+			return err
+		}
+
+		if connected {
+			attempt = 0
+		}
+		wait := c.backoff(attempt)
+		attempt++
+
+		log.Printf("Socket mode connection lost (%s), reconnecting in %s\n", err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// serve opens a connection and handles envelopes until it drops, reporting
+// whether slack said hello first
+func (c *Client) serve(ctx context.Context) (bool, error) {
+	wsURL, err := c.API.OpenConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := dial(ctx, wsURL)
+	if err != nil {
+		return false, err
+	}
+	defer conn.close()
+
+	interval := c.pingInterval()
+	conn.readTimeout = 3 * interval
+
+	// let in-flight envelopes finish acknowledging before reconnecting
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				// unblocks the read below
+				conn.close()
+				return
+			case <-ticker.C:
+				if err := conn.ping(); err != nil {
+					log.Printf("Error while pinging slack: %s\n", err.Error())
+				}
+			}
+		}
+	}()
+
+	connected := false
+	for {
+		message, err := conn.readMessage()
+		if err != nil {
+			return connected, err
+		}
+
+		var envelope Envelope
+		if err := json.Unmarshal(message, &envelope); err != nil {
+			log.Printf("Skipping malformed socket mode message: %s\n", err.Error())
+			continue
+		}
+
+		switch envelope.Type {
+		case HelloType:
+			log.Printf("Socket mode connected\n")
+			connected = true
+		case DisconnectType:
+			if envelope.Reason == "link_disabled" {
+				return connected, ErrLinkDisabled
+			}
+			return connected, errors.New("slack asked to reconnect: " + envelope.Reason)
+		default:
+			if envelope.EnvelopeID == "" {
+				log.Printf("Ignoring %s message without an envelope id\n", envelope.Type)
+				continue
+			}
+
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				c.handle(ctx, conn, envelope)
+			}()
+		}
+	}
+}
+
+// handles an envelope and acknowledges it. Failures are acknowledged too, as
+// slack would otherwise redeliver the envelope and run the code twice
+func (c *Client) handle(ctx context.Context, conn *conn, envelope Envelope) {
+	if envelope.RetryAttempt > 0 {
+		log.Printf("Handling retry %d of envelope %s (%s)\n", envelope.RetryAttempt, envelope.EnvelopeID, envelope.RetryReason)
+	}
+
+	payload, err := c.Handler(ctx, envelope)
+	if err != nil {
+		log.Printf("Error while handling %s envelope: %s\n", envelope.Type, err.Error())
+	}
+
+	ack := acknowledgement{EnvelopeID: envelope.EnvelopeID}
+	if envelope.AcceptsResponsePayload && json.Valid(payload) {
+		ack.Payload = payload
+	}
+
+	message, err := json.Marshal(ack)
+	if err != nil {
+		log.Printf("Error while serializing acknowledgement: %s\n", err.Error())
+		return
+	}
+
+	if err = conn.writeMessage(message); err != nil {
+		log.Printf("Error while acknowledging envelope %s: %s\n", envelope.EnvelopeID, err.Error())
+	}
+}
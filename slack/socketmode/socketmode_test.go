@@ -0,0 +1,277 @@
+package socketmode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// fakeSlack hands out socket mode urls and runs each connection made to them
+// with the next of its scripts
+type fakeSlack struct {
+	t       *testing.T
+	server  *httptest.Server
+	scripts []func(ws *websocket.Conn)
+
+	mu          sync.Mutex
+	connections int
+}
+
+func newFakeSlack(t *testing.T, scripts ...func(ws *websocket.Conn)) *fakeSlack {
+	f := &fakeSlack{t: t, scripts: scripts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer xapp-test" {
+			t.Errorf("expected the app token, got %q", auth)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":  true,
+			"url": "ws" + strings.TrimPrefix(f.server.URL, "http") + "/link",
+		})
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		n := f.connections
+		f.connections++
+		f.mu.Unlock()
+
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %s", err)
+			return
+		}
+		defer ws.Close()
+
+		if n >= len(f.scripts) {
+			t.Errorf("unexpected connection %d", n+1)
+			return
+		}
+		f.scripts[n](ws)
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeSlack) client(handler Handler) *Client {
+	c := New("xapp-test", handler)
+	c.API.BaseURL = f.server.URL
+	return c
+}
+
+func send(t *testing.T, ws *websocket.Conn, message interface{}) {
+	t.Helper()
+	if err := ws.WriteJSON(message); err != nil {
+		t.Errorf("write failed: %s", err)
+	}
+}
+
+// expectAck reads the acknowledgement of an envelope
+func expectAck(t *testing.T, ws *websocket.Conn, envelopeID string) acknowledgement {
+	t.Helper()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ack acknowledgement
+	if err := ws.ReadJSON(&ack); err != nil {
+		t.Errorf("expected an acknowledgement of %s: %s", envelopeID, err)
+		return ack
+	}
+	if ack.EnvelopeID != envelopeID {
+		t.Errorf("expected an acknowledgement of %s, got %s", envelopeID, ack.EnvelopeID)
+	}
+	return ack
+}
+
+// waitClosed reads until the client closes the connection
+func waitClosed(ws *websocket.Conn) {
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func TestClientReconnectsAfterDisconnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	f := newFakeSlack(t,
+		func(ws *websocket.Conn) {
+			send(t, ws, Envelope{Type: HelloType})
+			send(t, ws, Envelope{EnvelopeID: "E1", Type: SlashCommandsType, Payload: json.RawMessage(`{"text":"first"}`), AcceptsResponsePayload: true})
+			ack := expectAck(t, ws, "E1")
+			if string(ack.Payload) != `{"text":"got first"}` {
+				t.Errorf("expected the response payload in the acknowledgement, got %s", ack.Payload)
+			}
+			send(t, ws, Envelope{Type: DisconnectType, Reason: "refresh_requested"})
+			waitClosed(ws)
+		},
+		func(ws *websocket.Conn) {
+			send(t, ws, Envelope{Type: HelloType})
+			send(t, ws, Envelope{EnvelopeID: "E2", Type: EventsAPIType, Payload: json.RawMessage(`{"text":"second"}`)})
+			if ack := expectAck(t, ws, "E2"); ack.Payload != nil {
+				t.Errorf("expected no payload when slack doesn't accept one, got %s", ack.Payload)
+			}
+			cancel()
+			waitClosed(ws)
+		},
+	)
+
+	var mu sync.Mutex
+	var handled []string
+	err := f.client(func(ctx context.Context, envelope Envelope) (json.RawMessage, error) {
+		var payload struct{ Text string }
+		json.Unmarshal(envelope.Payload, &payload)
+
+		mu.Lock()
+		handled = append(handled, envelope.Type+" "+payload.Text)
+		mu.Unlock()
+		return json.RawMessage(`{"text":"got ` + payload.Text + `"}`), nil
+	}).Run(ctx)
+
+	if err != context.Canceled {
+		t.Errorf("expected Run to stop when canceled, got %v", err)
+	}
+	if strings.Join(handled, ", ") != "slash_commands first, events_api second" {
+		t.Errorf("expected an envelope handled on each connection, got %v", handled)
+	}
+}
+
+func TestClientStopsWhenLinkDisabled(t *testing.T) {
+	f := newFakeSlack(t, func(ws *websocket.Conn) {
+		send(t, ws, Envelope{Type: HelloType})
+		send(t, ws, Envelope{Type: DisconnectType, Reason: "link_disabled"})
+		waitClosed(ws)
+	})
+
+	err := f.client(func(ctx context.Context, envelope Envelope) (json.RawMessage, error) {
+		return nil, nil
+	}).Run(context.Background())
+	if err != ErrLinkDisabled {
+		t.Errorf("expected ErrLinkDisabled, got %v", err)
+	}
+}
+
+func TestClientReadsLargeFragmentedMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// past the 16 bit length and split over frames by the writer's buffer
+	text := strings.Repeat("x", 100000)
+	f := newFakeSlack(t, func(ws *websocket.Conn) {
+		send(t, ws, Envelope{Type: HelloType})
+
+		message, _ := json.Marshal(Envelope{EnvelopeID: "E1", Type: InteractiveType, Payload: json.RawMessage(`{"text":"` + text + `"}`)})
+		w, err := ws.NextWriter(websocket.TextMessage)
+		if err != nil {
+			t.Errorf("write failed: %s", err)
+			return
+		}
+		for len(message) > 0 {
+			n := 1000
+			if n > len(message) {
+				n = len(message)
+			}
+			w.Write(message[:n])
+			message = message[n:]
+		}
+		w.Close()
+
+		expectAck(t, ws, "E1")
+		cancel()
+		waitClosed(ws)
+	})
+
+	var got string
+	f.client(func(ctx context.Context, envelope Envelope) (json.RawMessage, error) {
+		var payload struct{ Text string }
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			t.Errorf("bad payload: %s", err)
+		}
+		got = payload.Text
+		return nil, nil
+	}).Run(ctx)
+
+	if got != text {
+		t.Errorf("expected the %d character payload intact, got %d characters", len(text), len(got))
+	}
+}
+
+func TestClientPingsAndAnswersPings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pinged := make(chan struct{}, 1)
+	ponged := make(chan string, 1)
+	f := newFakeSlack(t, func(ws *websocket.Conn) {
+		ws.SetPingHandler(func(data string) error {
+			select {
+			case pinged <- struct{}{}:
+			default:
+			}
+			return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		ws.SetPongHandler(func(data string) error {
+			ponged <- data
+			return nil
+		})
+
+		send(t, ws, Envelope{Type: HelloType})
+		if err := ws.WriteControl(websocket.PingMessage, []byte("are you there"), time.Now().Add(time.Second)); err != nil {
+			t.Errorf("ping failed: %s", err)
+		}
+
+		// control frames are handled while reading
+		go func() {
+			select {
+			case data := <-ponged:
+				if data != "are you there" {
+					t.Errorf("expected the ping echoed back, got %q", data)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("expected a pong")
+			}
+			select {
+			case <-pinged:
+			case <-time.After(5 * time.Second):
+				t.Errorf("expected a ping")
+			}
+			cancel()
+		}()
+		waitClosed(ws)
+	})
+
+	c := f.client(func(ctx context.Context, envelope Envelope) (json.RawMessage, error) {
+		return nil, nil
+	})
+	c.PingInterval = 50 * time.Millisecond
+	c.Run(ctx)
+}
+
+func TestClientGivesUpOnRejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+	}))
+	defer server.Close()
+
+	c := New("xapp-test", nil)
+	c.API.BaseURL = server.URL
+
+	err := c.Run(context.Background())
+	if apiErr, ok := err.(*slack.APIError); !ok || apiErr.Code != "invalid_auth" {
+		t.Errorf("expected the token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package socketmode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxMessageSize bounds the messages read from slack
+const maxMessageSize = 16 << 20
+
+// handshakeTimeout bounds the websocket handshake
+const handshakeTimeout = 30 * time.Second
+
+// writeTimeout bounds each message written
+const writeTimeout = 10 * time.Second
+
+// conn is a socket mode websocket: text messages one writer at a time, pings
+// and a read deadline that drops a connection gone quiet
+type conn struct {
+	ws *websocket.Conn
+	// readTimeout drops a connection that has gone quiet; zero waits forever
+	readTimeout time.Duration
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+// dial opens a websocket to a ws:// or wss:// url
+func dial(ctx context.Context, url string) (*conn, error) {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: handshakeTimeout,
+	}
+
+	ws, resp, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed with status %d: %w", resp.StatusCode, err)
+		}
+		return nil, err
+	}
+	ws.SetReadLimit(maxMessageSize)
+
+	c := &conn{ws: ws}
+
+	// any sign of life from slack counts towards the read deadline
+	ws.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+	ws.SetPingHandler(func(data string) error {
+		c.extendReadDeadline()
+		err := ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	return c, nil
+}
+
+func (c *conn) extendReadDeadline() {
+	if c.readTimeout > 0 {
+		c.ws.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// readMessage returns the next text or binary message, answering pings and
+// closes along the way
+func (c *conn) readMessage() ([]byte, error) {
+	c.extendReadDeadline()
+	_, message, err := c.ws.ReadMessage()
+	return message, err
+}
+
+// ping asks slack for a pong; it is safe for concurrent use
+func (c *conn) ping() error {
+	return c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+}
+
+// writeMessage sends a text message; it is safe for concurrent use
+func (c *conn) writeMessage(message []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, message)
+}
+
+// close says goodbye and closes the connection; it may be called more than once
+func (c *conn) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		goodbye := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		c.ws.WriteControl(websocket.CloseMessage, goodbye, time.Now().Add(writeTimeout))
+		err = c.ws.Close()
+	})
+	return err
+}
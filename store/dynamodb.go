@@ -0,0 +1,334 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// DynamoDB keeps everything in DynamoDB tables: runs keyed by "owner" (team
+// and user), sorted by "id" and deleted once "expiresAt" has passed, users
+// keyed by "key" (team and user), snippets keyed by "owner" (team and
+// namespace) and sorted by "name", installations keyed by "key" (team, or
+// enterprise for org-wide installs) and claimed events keyed by "key" (the
+// event id) that DynamoDB deletes once "expiresAt" has passed
+type DynamoDB struct {
+	RunsTable          string
+	UsersTable         string
+	SnippetsTable      string
+	InstallationsTable string
+	EventsTable        string
+	Client             *dynamodb.DynamoDB
+}
+
+// runLifetime is how long a run stays in the user's history before the
+// runs table's TTL deletes it
+const runLifetime = 90 * 24 * time.Hour
+
+// the item attributes holding each table's keys
+type runItem struct {
+	Owner string `json:"owner"`
+	// ExpiresAt is the unix time the table's TTL deletes the item after
+	ExpiresAt int64 `json:"expiresAt"`
+	Run
+}
+
+type userItem struct {
+	Key string `json:"key"`
+	User
+}
+
+type snippetItem struct {
+	Owner string `json:"owner"`
+	Snippet
+}
+
+type installationItem struct {
+	Key string `json:"key"`
+	slack.Installation
+}
+
+type eventItem struct {
+	Key string `json:"key"`
+	// ExpiresAt is the unix time the table's TTL deletes the item after
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// NewDynamoDB returns a store for the configured tables using the default AWS
+// session for the current region
+func NewDynamoDB(config Config) *DynamoDB {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	return &DynamoDB{
+		RunsTable:          config.RunsTable,
+		UsersTable:         config.UsersTable,
+		SnippetsTable:      config.SnippetsTable,
+		InstallationsTable: config.InstallationsTable,
+		EventsTable:        config.EventsTable,
+		Client:             dynamodb.New(sess, &aws.Config{Region: aws.String(os.Getenv("AWS_REGION"))}),
+	}
+}
+
+func (d *DynamoDB) put(ctx context.Context, table string, item interface{}) error {
+	attributes, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      attributes,
+	})
+	return err
+}
+
+// get reads the item with the key into out, reporting whether there was one
+func (d *DynamoDB) get(ctx context.Context, table string, key map[string]*dynamodb.AttributeValue, out interface{}) (bool, error) {
+	result, err := d.Client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       key,
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(result.Item) == 0 {
+		return false, nil
+	}
+
+	return true, dynamodbattribute.UnmarshalMap(result.Item, out)
+}
+
+func stringKey(name, value string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		name: {S: aws.String(value)},
+	}
+}
+
+// SaveRun stores the run until runLifetime after it was made
+func (d *DynamoDB) SaveRun(ctx context.Context, run Run) error {
+	return d.put(ctx, d.RunsTable, runItem{
+		Owner:     userKey(run.TeamID, run.UserID),
+		ExpiresAt: run.CreatedAt.Add(runLifetime).Unix(),
+		Run:       run,
+	})
+}
+
+// ownerQuery returns the query for the items with the owner
+func (d *DynamoDB) ownerQuery(table, owner string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+		},
+	}
+}
+
+// RecentRuns returns up to limit of the user's runs, newest first
+func (d *DynamoDB) RecentRuns(ctx context.Context, teamID, userID string, limit int) ([]Run, error) {
+	query := d.ownerQuery(d.RunsTable, userKey(teamID, userID))
+	// ids sort by time, so this is newest first
+	query.ScanIndexForward = aws.Bool(false)
+	query.Limit = aws.Int64(int64(limit))
+
+	result, err := d.Client.QueryWithContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []runItem
+	if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items); err != nil {
+		return nil, err
+	}
+
+	runs := make([]Run, len(items))
+	for i, item := range items {
+		runs[i] = item.Run
+	}
+	return runs, nil
+}
+
+// FindRun returns one of the user's runs
+func (d *DynamoDB) FindRun(ctx context.Context, teamID, userID, runID string) (Run, error) {
+	key := stringKey("owner", userKey(teamID, userID))
+	key["id"] = &dynamodb.AttributeValue{S: aws.String(runID)}
+
+	var item runItem
+	found, err := d.get(ctx, d.RunsTable, key, &item)
+	if err != nil {
+		return Run{}, err
+	}
+	if !found {
+		return Run{}, ErrNotFound
+	}
+	return item.Run, nil
+}
+
+// SaveUser stores the user, replacing any earlier version
+func (d *DynamoDB) SaveUser(ctx context.Context, user User) error {
+	return d.put(ctx, d.UsersTable, userItem{
+		Key:  userKey(user.TeamID, user.ID),
+		User: user,
+	})
+}
+
+// FindUser returns the user
+func (d *DynamoDB) FindUser(ctx context.Context, teamID, userID string) (User, error) {
+	var item userItem
+	found, err := d.get(ctx, d.UsersTable, stringKey("key", userKey(teamID, userID)), &item)
+	if err != nil {
+		return User{}, err
+	}
+	if !found {
+		return User{}, ErrNotFound
+	}
+	return item.User, nil
+}
+
+// SaveSnippet stores the snippet, replacing any of the same name in its namespace
+func (d *DynamoDB) SaveSnippet(ctx context.Context, snippet Snippet) error {
+	return d.put(ctx, d.SnippetsTable, snippetItem{
+		Owner:   namespaceKey(snippet.TeamID, snippet.Namespace),
+		Snippet: snippet,
+	})
+}
+
+func snippetKey(teamID, namespace, name string) map[string]*dynamodb.AttributeValue {
+	key := stringKey("owner", namespaceKey(teamID, namespace))
+	key["name"] = &dynamodb.AttributeValue{S: aws.String(name)}
+	return key
+}
+
+// FindSnippet returns the named snippet
+func (d *DynamoDB) FindSnippet(ctx context.Context, teamID, namespace, name string) (Snippet, error) {
+	var item snippetItem
+	found, err := d.get(ctx, d.SnippetsTable, snippetKey(teamID, namespace, name), &item)
+	if err != nil {
+		return Snippet{}, err
+	}
+	if !found {
+		return Snippet{}, ErrNotFound
+	}
+	return item.Snippet, nil
+}
+
+// ListSnippets returns the namespace's snippets ordered by name
+func (d *DynamoDB) ListSnippets(ctx context.Context, teamID, namespace string) ([]Snippet, error) {
+	// names are the sort key, so the items come back in order
+	query := d.ownerQuery(d.SnippetsTable, namespaceKey(teamID, namespace))
+
+	var snippets []Snippet
+	for {
+		result, err := d.Client.QueryWithContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []snippetItem
+		if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			snippets = append(snippets, item.Snippet)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		query.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return snippets, nil
+}
+
+// DeleteSnippet removes the named snippet
+func (d *DynamoDB) DeleteSnippet(ctx context.Context, teamID, namespace, name string) error {
+	result, err := d.Client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:    aws.String(d.SnippetsTable),
+		Key:          snippetKey(teamID, namespace, name),
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Attributes) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveInstallation stores the installation, replacing any earlier one
+func (d *DynamoDB) SaveInstallation(ctx context.Context, installation slack.Installation) error {
+	return d.put(ctx, d.InstallationsTable, installationItem{
+		Key:          slack.InstallationKey(installation),
+		Installation: installation,
+	})
+}
+
+// FindInstallation returns the team's installation, falling back to its org's
+func (d *DynamoDB) FindInstallation(ctx context.Context, teamID, enterpriseID string) (slack.Installation, error) {
+	return slack.FindInstallationByKey(teamID, enterpriseID, func(key string) (slack.Installation, bool, error) {
+		var item installationItem
+		found, err := d.get(ctx, d.InstallationsTable, stringKey("key", key), &item)
+		return item.Installation, found, err
+	})
+}
+
+// ClaimEvent marks the event as handled. The claim is a conditional write so
+// that of two deliveries racing for it only one wins; expired claims the TTL
+// hasn't deleted yet don't count
+func (d *DynamoDB) ClaimEvent(ctx context.Context, eventID string) (bool, error) {
+	now := time.Now()
+	attributes, err := dynamodbattribute.MarshalMap(eventItem{
+		Key:       eventID,
+		ExpiresAt: now.Add(eventLifetime).Unix(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = d.Client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.EventsTable),
+		Item:                attributes,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expiresAt < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":       aws.String("key"),
+			"#expiresAt": aws.String("expiresAt"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseEvent forgets a claimed event
+func (d *DynamoDB) ReleaseEvent(ctx context.Context, eventID string) error {
+	_, err := d.Client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.EventsTable),
+		Key:       stringKey("key", eventID),
+	})
+	return err
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestRunItemAttributes(t *testing.T) {
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := Run{ID: "0001", TeamID: "T1", UserID: "U1", Language: "py3", CreatedAt: createdAt}
+
+	attributes, err := dynamodbattribute.MarshalMap(runItem{
+		Owner:     userKey(run.TeamID, run.UserID),
+		ExpiresAt: createdAt.Add(runLifetime).Unix(),
+		Run:       run,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the runs table's key schema and TTL in template.yml
+	if owner := attributes["owner"]; owner == nil || owner.S == nil || *owner.S != "T1:U1" {
+		t.Errorf("expected the owner as a string, got %v", owner)
+	}
+	if id := attributes["id"]; id == nil || id.S == nil || *id.S != "0001" {
+		t.Errorf("expected the id as a string, got %v", id)
+	}
+	want := strconv.FormatInt(createdAt.Add(runLifetime).Unix(), 10)
+	if expiresAt := attributes["expiresAt"]; expiresAt == nil || expiresAt.N == nil || *expiresAt.N != want {
+		t.Errorf("expected expiresAt as a number of seconds, got %v", expiresAt)
+	}
+}
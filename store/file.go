@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// maxFileRuns is how many of each user's runs the file store keeps
+const maxFileRuns = 100
+
+// File keeps everything in memory and, when it has a path, in a json file
+// that is rewritten after every change. It is meant for local development and
+// tests, not for more than one process at a time
+type File struct {
+	path string
+
+	mu   sync.Mutex
+	data fileData
+}
+
+type fileData struct {
+	// Runs holds each user's runs, oldest first
+	Runs  map[string][]Run `json:"runs"`
+	Users map[string]User  `json:"users"`
+	// Snippets holds each namespace's snippets by name
+	Snippets      map[string]map[string]Snippet `json:"snippets"`
+	Installations map[string]slack.Installation `json:"installations"`
+	// Events holds when each claimed event stops being remembered
+	Events map[string]time.Time `json:"events"`
+}
+
+// OpenFile loads the store kept at path, starting empty if the file does not
+// exist yet. An empty path keeps everything in memory
+func OpenFile(path string) (*File, error) {
+	f := &File{
+		path: path,
+		data: fileData{
+			Runs:          make(map[string][]Run),
+			Users:         make(map[string]User),
+			Snippets:      make(map[string]map[string]Snippet),
+			Installations: make(map[string]slack.Installation),
+			Events:        make(map[string]time.Time),
+		},
+	}
+	if path == "" {
+		return f, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data fileData
+	if err = json.Unmarshal(contents, &data); err != nil {
+		return nil, err
+	}
+	for key, runs := range data.Runs {
+		f.data.Runs[key] = runs
+	}
+	for key, user := range data.Users {
+		f.data.Users[key] = user
+	}
+	for key, snippets := range data.Snippets {
+		f.data.Snippets[key] = snippets
+	}
+	for key, installation := range data.Installations {
+		f.data.Installations[key] = installation
+	}
+	for key, expires := range data.Events {
+		f.data.Events[key] = expires
+	}
+	return f, nil
+}
+
+// writes the data to a temporary file and moves it over the old one, so a
+// crash never leaves half a file behind. Must be called with mu held
+func (f *File) save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	contents, err := json.MarshalIndent(f.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// TempFile leaves the file readable only by its owner, which matters as
+	// it holds bot tokens
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(contents); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// SaveRun stores the run, forgetting the user's oldest runs past maxFileRuns
+func (f *File) SaveRun(ctx context.Context, run Run) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := userKey(run.TeamID, run.UserID)
+	runs := append(f.data.Runs[key], run)
+	if len(runs) > maxFileRuns {
+		runs = runs[len(runs)-maxFileRuns:]
+	}
+	f.data.Runs[key] = runs
+
+	return f.save()
+}
+
+// RecentRuns returns up to limit of the user's runs, newest first
+func (f *File) RecentRuns(ctx context.Context, teamID, userID string, limit int) ([]Run, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	runs := f.data.Runs[userKey(teamID, userID)]
+	recent := make([]Run, 0, limit)
+	for i := len(runs) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, runs[i])
+	}
+	return recent, nil
+}
+
+// FindRun returns one of the user's runs
+func (f *File) FindRun(ctx context.Context, teamID, userID, runID string) (Run, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, run := range f.data.Runs[userKey(teamID, userID)] {
+		if run.ID == runID {
+			return run, nil
+		}
+	}
+	return Run{}, ErrNotFound
+}
+
+// SaveUser stores the user, replacing any earlier version
+func (f *File) SaveUser(ctx context.Context, user User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data.Users[userKey(user.TeamID, user.ID)] = user
+	return f.save()
+}
+
+// FindUser returns the user
+func (f *File) FindUser(ctx context.Context, teamID, userID string) (User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, found := f.data.Users[userKey(teamID, userID)]
+	if !found {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// SaveSnippet stores the snippet, replacing any of the same name in its namespace
+func (f *File) SaveSnippet(ctx context.Context, snippet Snippet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := namespaceKey(snippet.TeamID, snippet.Namespace)
+	if f.data.Snippets[key] == nil {
+		f.data.Snippets[key] = make(map[string]Snippet)
+	}
+	f.data.Snippets[key][snippet.Name] = snippet
+	return f.save()
+}
+
+// FindSnippet returns the named snippet
+func (f *File) FindSnippet(ctx context.Context, teamID, namespace, name string) (Snippet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snippet, found := f.data.Snippets[namespaceKey(teamID, namespace)][name]
+	if !found {
+		return Snippet{}, ErrNotFound
+	}
+	return snippet, nil
+}
+
+// ListSnippets returns the namespace's snippets ordered by name
+func (f *File) ListSnippets(ctx context.Context, teamID, namespace string) ([]Snippet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snippets := []Snippet{}
+	for _, snippet := range f.data.Snippets[namespaceKey(teamID, namespace)] {
+		snippets = append(snippets, snippet)
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].Name < snippets[j].Name
+	})
+	return snippets, nil
+}
+
+// DeleteSnippet removes the named snippet
+func (f *File) DeleteSnippet(ctx context.Context, teamID, namespace, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := namespaceKey(teamID, namespace)
+	if _, found := f.data.Snippets[key][name]; !found {
+		return ErrNotFound
+	}
+	delete(f.data.Snippets[key], name)
+	return f.save()
+}
+
+// SaveInstallation stores the installation, replacing any earlier one
+func (f *File) SaveInstallation(ctx context.Context, installation slack.Installation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data.Installations[slack.InstallationKey(installation)] = installation
+	return f.save()
+}
+
+// FindInstallation returns the team's installation, falling back to its org's
+func (f *File) FindInstallation(ctx context.Context, teamID, enterpriseID string) (slack.Installation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slack.FindInstallationByKey(teamID, enterpriseID, func(key string) (slack.Installation, bool, error) {
+		installation, found := f.data.Installations[key]
+		return installation, found, nil
+	})
+}
+
+// ClaimEvent marks the event as handled, forgetting events claimed longer
+// than eventLifetime ago
+func (f *File) ClaimEvent(ctx context.Context, eventID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for key, expires := range f.data.Events {
+		if now.After(expires) {
+			delete(f.data.Events, key)
+		}
+	}
+
+	if _, found := f.data.Events[eventID]; found {
+		return false, nil
+	}
+	f.data.Events[eventID] = now.Add(eventLifetime)
+	return true, f.save()
+}
+
+// ReleaseEvent forgets a claimed event
+func (f *File) ReleaseEvent(ctx context.Context, eventID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.data.Events, eventID)
+	return f.save()
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+func TestFileRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "resl.json")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := Run{ID: NewRunID(), TeamID: "T1", UserID: "U1", Language: "py3", Code: "print(1)", CreatedAt: time.Now().UTC()}
+	if err = Record(ctx, f, run); err != nil {
+		t.Fatal(err)
+	}
+	snippet := Snippet{TeamID: "T1", Namespace: ChannelNamespace("C1"), Name: "fib", Language: "py3", Code: "print(1)"}
+	if err = f.SaveSnippet(ctx, snippet); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.SaveInstallation(ctx, slack.Installation{TeamID: "T1", BotToken: "xoxb-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.ClaimEvent(ctx, "Ev1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := reopened.FindRun(ctx, "T1", "U1", run.ID); err != nil || found.Code != run.Code {
+		t.Errorf("expected the run back, got %+v, %v", found, err)
+	}
+	if user, err := reopened.FindUser(ctx, "T1", "U1"); err != nil || user.RunCount != 1 || user.LastLanguage != "py3" {
+		t.Errorf("expected the user counted once, got %+v, %v", user, err)
+	}
+	if found, err := reopened.FindSnippet(ctx, "T1", ChannelNamespace("C1"), "fib"); err != nil || found.Code != snippet.Code {
+		t.Errorf("expected the snippet back, got %+v, %v", found, err)
+	}
+	if installation, err := reopened.FindInstallation(ctx, "T1", ""); err != nil || installation.BotToken != "xoxb-1" {
+		t.Errorf("expected the installation back, got %+v, %v", installation, err)
+	}
+	if claimed, err := reopened.ClaimEvent(ctx, "Ev1"); err != nil || claimed {
+		t.Errorf("expected the event to stay claimed, got %v, %v", claimed, err)
+	}
+}
+
+func TestFileKeepsLatestRuns(t *testing.T) {
+	ctx := context.Background()
+	f, err := OpenFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i := 0; i < maxFileRuns+5; i++ {
+		run := Run{ID: NewRunID(), TeamID: "T1", UserID: "U1"}
+		ids = append(ids, run.ID)
+		if err = f.SaveRun(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := f.RecentRuns(ctx, "T1", "U1", 2*maxFileRuns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != maxFileRuns {
+		t.Fatalf("expected %d runs kept, got %d", maxFileRuns, len(runs))
+	}
+	if runs[0].ID != ids[len(ids)-1] || runs[len(runs)-1].ID != ids[5] {
+		t.Errorf("expected the newest runs, newest first")
+	}
+	if _, err = f.FindRun(ctx, "T1", "U1", ids[4]); err != ErrNotFound {
+		t.Errorf("expected the oldest runs forgotten, got %v", err)
+	}
+}
+
+func TestFileClaimEvent(t *testing.T) {
+	ctx := context.Background()
+	f, err := OpenFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := f.ClaimEvent(ctx, "Ev1"); err != nil || !claimed {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", claimed, err)
+	}
+	if claimed, err := f.ClaimEvent(ctx, "Ev1"); err != nil || claimed {
+		t.Errorf("expected a second claim to be refused, got %v, %v", claimed, err)
+	}
+
+	// as if the claim was made eventLifetime ago
+	f.data.Events["Ev1"] = time.Now().Add(-time.Second)
+	if claimed, err := f.ClaimEvent(ctx, "Ev1"); err != nil || !claimed {
+		t.Errorf("expected an expired claim to be made again, got %v, %v", claimed, err)
+	}
+
+	if err = f.ReleaseEvent(ctx, "Ev1"); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := f.ClaimEvent(ctx, "Ev1"); err != nil || !claimed {
+		t.Errorf("expected a released event to be claimed again, got %v, %v", claimed, err)
+	}
+}
+
+func TestFileDeleteSnippet(t *testing.T) {
+	ctx := context.Background()
+	f, err := OpenFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespace := UserNamespace("U1")
+	if err = f.DeleteSnippet(ctx, "T1", namespace, "fib"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a missing snippet, got %v", err)
+	}
+
+	if err = f.SaveSnippet(ctx, Snippet{TeamID: "T1", Namespace: namespace, Name: "fib"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.DeleteSnippet(ctx, "T2", namespace, "fib"); err != ErrNotFound {
+		t.Errorf("expected another team's snippet to be out of reach, got %v", err)
+	}
+	if err = f.DeleteSnippet(ctx, "T1", namespace, "fib"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.FindSnippet(ctx, "T1", namespace, "fib"); err != ErrNotFound {
+		t.Errorf("expected the snippet gone, got %v", err)
+	}
+}
+
+func TestNewRunClipsOutput(t *testing.T) {
+	long := strings.Repeat("x", maxStoredOutput+100)
+	run := NewRun(models.CodeProcessRequest{UserID: "U1", Code: "print(1)"}, models.CodeOutput{
+		Compile: &models.ExecutionResult{Stderr: long},
+		Run:     &models.ExecutionResult{Stdout: long, Stderr: "short"},
+	})
+
+	if n := len(run.Output.Compile.Stderr); n > maxStoredOutput {
+		t.Errorf("expected compile output clipped to %d bytes, got %d", maxStoredOutput, n)
+	}
+	if n := len(run.Output.Run.Stdout); n > maxStoredOutput {
+		t.Errorf("expected run output clipped to %d bytes, got %d", maxStoredOutput, n)
+	}
+	if run.Output.Run.Stderr != "short" {
+		t.Errorf("expected short output kept, got %q", run.Output.Run.Stderr)
+	}
+	if run.ID == "" || run.CreatedAt.IsZero() {
+		t.Errorf("expected the run to get an id and time, got %+v", run)
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/stripedpajamas/resl/store
+
+go 1.15
+
+require (
+	github.com/aws/aws-sdk-go v1.36.12
+	github.com/stripedpajamas/resl/models v0.0.0-00010101000000-000000000000
+	github.com/stripedpajamas/resl/slack v0.0.0-00010101000000-000000000000
+)
+
+replace (
+	github.com/stripedpajamas/resl/models => ../models
+	github.com/stripedpajamas/resl/slack => ../slack
+)
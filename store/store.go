@@ -0,0 +1,236 @@
+// Package store keeps what resl remembers between requests: the code each
+// user ran, the users themselves and the workspaces the app is installed in
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stripedpajamas/resl/models"
+	"github.com/stripedpajamas/resl/slack"
+)
+
+// Backend names accepted by New
+const (
+	DynamoDBBackend = "dynamodb"
+	FileBackend     = "file"
+)
+
+// maxStoredOutput bounds each stream of a run's output that is kept, so a
+// noisy program can't outgrow a DynamoDB item
+const maxStoredOutput = 8000
+
+// eventLifetime is how long a handled event is remembered, well past the
+// hour or so over which slack retries it
+const eventLifetime = 24 * time.Hour
+
+// ErrNotFound is returned when a run, user or snippet does not exist
+var ErrNotFound = errors.New("not found")
+
+// Run is a piece of code that was run and what it printed
+type Run struct {
+	ID        string            `json:"id"`
+	TeamID    string            `json:"teamId,omitempty"`
+	UserID    string            `json:"userId"`
+	ChannelID string            `json:"channelId,omitempty"`
+	Language  string            `json:"language"`
+	Code      string            `json:"code"`
+	Stdin     string            `json:"stdin,omitempty"`
+	Output    models.CodeOutput `json:"output"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// User is someone who has run code with resl
+type User struct {
+	TeamID string `json:"teamId,omitempty"`
+	ID     string `json:"id"`
+	// LastLanguage is the language of the user's latest run
+	LastLanguage string    `json:"lastLanguage,omitempty"`
+	RunCount     int       `json:"runCount"`
+	FirstSeenAt  time.Time `json:"firstSeenAt"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+}
+
+// Snippet is code saved under a name so it can be run again. Snippets belong
+// to a namespace: a user's own, or a channel's shared with everyone in it
+type Snippet struct {
+	TeamID    string    `json:"teamId,omitempty"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Language  string    `json:"language"`
+	Code      string    `json:"code"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserNamespace is the namespace of the snippets only the user can see
+func UserNamespace(userID string) string {
+	return "user:" + userID
+}
+
+// ChannelNamespace is the namespace of the snippets shared in the channel
+func ChannelNamespace(channelID string) string {
+	return "channel:" + channelID
+}
+
+// RunStore keeps the runs of each user
+type RunStore interface {
+	SaveRun(ctx context.Context, run Run) error
+	// RecentRuns returns up to limit of the user's runs, newest first
+	RecentRuns(ctx context.Context, teamID, userID string, limit int) ([]Run, error)
+	// FindRun returns one of the user's runs or ErrNotFound
+	FindRun(ctx context.Context, teamID, userID, runID string) (Run, error)
+}
+
+// UserStore keeps the users who have run code
+type UserStore interface {
+	SaveUser(ctx context.Context, user User) error
+	// FindUser returns the user or ErrNotFound
+	FindUser(ctx context.Context, teamID, userID string) (User, error)
+}
+
+// SnippetStore keeps named snippets
+type SnippetStore interface {
+	// SaveSnippet stores the snippet, replacing any of the same name in its namespace
+	SaveSnippet(ctx context.Context, snippet Snippet) error
+	// FindSnippet returns the named snippet or ErrNotFound
+	FindSnippet(ctx context.Context, teamID, namespace, name string) (Snippet, error)
+	// ListSnippets returns the namespace's snippets ordered by name
+	ListSnippets(ctx context.Context, teamID, namespace string) ([]Snippet, error)
+	// DeleteSnippet removes the named snippet or returns ErrNotFound
+	DeleteSnippet(ctx context.Context, teamID, namespace, name string) error
+}
+
+// EventStore remembers the Events API deliveries being handled, since slack
+// delivers an event again when it isn't answered within a few seconds
+type EventStore interface {
+	// ClaimEvent marks the event as handled, reporting false when it already was
+	ClaimEvent(ctx context.Context, eventID string) (bool, error)
+	// ReleaseEvent forgets a claimed event so that slack's retry is handled
+	ReleaseEvent(ctx context.Context, eventID string) error
+}
+
+// Store keeps everything resl persists
+type Store interface {
+	RunStore
+	UserStore
+	SnippetStore
+	EventStore
+	slack.InstallationStore
+}
+
+// Config selects and configures a Store backend
+type Config struct {
+	// Backend is DynamoDBBackend or FileBackend
+	Backend string
+	// RunsTable, UsersTable, SnippetsTable, InstallationsTable and
+	// EventsTable are the DynamoDB tables
+	RunsTable          string
+	UsersTable         string
+	SnippetsTable      string
+	InstallationsTable string
+	EventsTable        string
+	// Path is the json file for FileBackend; without one nothing outlives the process
+	Path string
+}
+
+// ConfigFromEnv returns the configuration in the RESL_STORE, RESL_STORE_PATH,
+// RESL_RUNS_TABLE, RESL_USERS_TABLE, RESL_SNIPPETS_TABLE,
+// RESL_INSTALLATIONS_TABLE and RESL_EVENTS_TABLE environment variables. Backend is empty when there
+// is no store to use
+func ConfigFromEnv() Config {
+	return Config{
+		Backend:            os.Getenv("RESL_STORE"),
+		RunsTable:          os.Getenv("RESL_RUNS_TABLE"),
+		UsersTable:         os.Getenv("RESL_USERS_TABLE"),
+		SnippetsTable:      os.Getenv("RESL_SNIPPETS_TABLE"),
+		InstallationsTable: os.Getenv("RESL_INSTALLATIONS_TABLE"),
+		EventsTable:        os.Getenv("RESL_EVENTS_TABLE"),
+		Path:               os.Getenv("RESL_STORE_PATH"),
+	}
+}
+
+// New returns the Store for the configured backend
+func New(config Config) (Store, error) {
+	switch config.Backend {
+	case DynamoDBBackend:
+		if config.RunsTable == "" || config.UsersTable == "" || config.SnippetsTable == "" || config.InstallationsTable == "" || config.EventsTable == "" {
+			return nil, errors.New("dynamodb store needs a runs, users, snippets, installations and events table")
+		}
+		return NewDynamoDB(config), nil
+	case FileBackend:
+		return OpenFile(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", config.Backend)
+	}
+}
+
+// NewRunID returns an id that sorts after the ids made before it
+func NewRunID() string {
+	random := make([]byte, 3)
+	rand.Read(random)
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(random))
+}
+
+func clipResult(result *models.ExecutionResult) *models.ExecutionResult {
+	if result == nil {
+		return nil
+	}
+	clipped := *result
+	clipped.Stdout = slack.Clip(clipped.Stdout, maxStoredOutput)
+	clipped.Stderr = slack.Clip(clipped.Stderr, maxStoredOutput)
+	return &clipped
+}
+
+// NewRun returns a run of the request, with a new id and its output cut down
+// to what is worth keeping
+func NewRun(request models.CodeProcessRequest, output models.CodeOutput) Run {
+	return Run{
+		ID:        NewRunID(),
+		TeamID:    request.TeamID,
+		UserID:    request.UserID,
+		ChannelID: request.ChannelID,
+		Language:  request.Props.ShortName,
+		Code:      request.Code,
+		Stdin:     request.Stdin,
+		Output: models.CodeOutput{
+			Compile: clipResult(output.Compile),
+			Run:     clipResult(output.Run),
+		},
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Record saves the run and counts it towards its user
+func Record(ctx context.Context, s Store, run Run) error {
+	if err := s.SaveRun(ctx, run); err != nil {
+		return err
+	}
+
+	user, err := s.FindUser(ctx, run.TeamID, run.UserID)
+	if errors.Is(err, ErrNotFound) {
+		user = User{TeamID: run.TeamID, ID: run.UserID, FirstSeenAt: run.CreatedAt}
+	} else if err != nil {
+		return err
+	}
+
+	user.LastLanguage = run.Language
+	user.RunCount++
+	user.LastSeenAt = run.CreatedAt
+	return s.SaveUser(ctx, user)
+}
+
+// userKey identifies a user across workspaces
+func userKey(teamID, userID string) string {
+	return teamID + ":" + userID
+}
+
+// namespaceKey identifies a snippet namespace across workspaces
+func namespaceKey(teamID, namespace string) string {
+	return teamID + ":" + namespace
+}